@@ -0,0 +1,162 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BitField builds a BITFIELD command. The methods append subcommands to the
+// command and return the builder, so that calls can be chained:
+//
+//	values, err := redis.NewBitField("counters").
+//	    Overflow("SAT").
+//	    IncrBy("u8", "#0", 1).
+//	    Get("u8", "#1").
+//	    Do(c)
+//
+// Types are written as i or u followed by the number of bits, for example
+// "i16" or "u8". Offsets are bit offsets such as "100", or offsets prefixed
+// with # such as "#2", which are multiplied by the width of the type. The
+// first invalid argument is reported by Do.
+type BitField struct {
+	args []interface{}
+	ops  int
+	err  error
+}
+
+// NewBitField returns a builder for a BITFIELD command on key.
+func NewBitField(key string) *BitField {
+	return &BitField{args: []interface{}{key}}
+}
+
+// Get appends a GET subcommand that returns the field at offset.
+func (b *BitField) Get(typ string, offset string) *BitField {
+	return b.op("GET", typ, offset)
+}
+
+// Set appends a SET subcommand that sets the field at offset to value and
+// returns the old value of the field.
+func (b *BitField) Set(typ string, offset string, value int64) *BitField {
+	return b.op("SET", typ, offset, value)
+}
+
+// IncrBy appends an INCRBY subcommand that increments the field at offset
+// by increment and returns the new value of the field.
+func (b *BitField) IncrBy(typ string, offset string, increment int64) *BitField {
+	return b.op("INCRBY", typ, offset, increment)
+}
+
+// Overflow appends an OVERFLOW subcommand that sets the overflow behavior of
+// the SET and INCRBY subcommands that follow it. The mode is WRAP, SAT or
+// FAIL.
+func (b *BitField) Overflow(mode string) *BitField {
+	mode = strings.ToUpper(mode)
+	switch mode {
+	case "WRAP", "SAT", "FAIL":
+		b.args = append(b.args, "OVERFLOW", mode)
+	default:
+		b.setErr(fmt.Errorf("redigo: invalid BITFIELD overflow mode %q", mode))
+	}
+	return b
+}
+
+func (b *BitField) op(subcommand string, typ string, offset string, value ...interface{}) *BitField {
+	if err := checkBitFieldType(typ); err != nil {
+		b.setErr(err)
+		return b
+	}
+	if err := checkBitFieldOffset(offset); err != nil {
+		b.setErr(err)
+		return b
+	}
+	b.args = append(b.args, subcommand, typ, offset)
+	b.args = append(b.args, value...)
+	b.ops++
+	return b
+}
+
+func (b *BitField) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Args returns the arguments of the BITFIELD command, starting with the key,
+// or the first invalid argument passed to the builder.
+func (b *BitField) Args() ([]interface{}, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.args, nil
+}
+
+// Do executes the BITFIELD command on c and returns a value for each GET,
+// SET and INCRBY subcommand in the order that the subcommands were added.
+// The value is nil for a SET or INCRBY subcommand that was not executed
+// because of overflow in FAIL mode.
+func (b *BitField) Do(c Conn) ([]*int64, error) {
+	args, err := b.Args()
+	if err != nil {
+		return nil, err
+	}
+	values, err := Values(c.Do("BITFIELD", args...))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != b.ops {
+		return nil, fmt.Errorf("redigo: BITFIELD returned %d values for %d subcommands", len(values), b.ops)
+	}
+	result := make([]*int64, len(values))
+	for i := range values {
+		if values[i] == nil {
+			continue
+		}
+		n, err := Int64(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = &n
+	}
+	return result, nil
+}
+
+// checkBitFieldType checks a type such as i16 or u8. Signed types have up to
+// 64 bits and unsigned types up to 63 bits.
+func checkBitFieldType(typ string) error {
+	if len(typ) >= 2 && (typ[0] == 'i' || typ[0] == 'u') && strings.Trim(typ[1:], "0123456789") == "" {
+		bits, err := strconv.Atoi(typ[1:])
+		max := 64
+		if typ[0] == 'u' {
+			max = 63
+		}
+		if err == nil && bits >= 1 && bits <= max {
+			return nil
+		}
+	}
+	return fmt.Errorf("redigo: invalid BITFIELD type %q", typ)
+}
+
+// checkBitFieldOffset checks a bit offset such as 100 or a type width
+// multiple such as #2.
+func checkBitFieldOffset(offset string) error {
+	s := strings.TrimPrefix(offset, "#")
+	if s != "" && strings.Trim(s, "0123456789") == "" {
+		return nil
+	}
+	return fmt.Errorf("redigo: invalid BITFIELD offset %q", offset)
+}
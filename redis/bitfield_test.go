@@ -0,0 +1,86 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestBitField(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		return "*3\r\n:0\r\n:1\r\n$-1\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	values, err := redis.NewBitField("k").
+		Set("i8", "0", 100).
+		Get("u4", "#2").
+		Overflow("fail").
+		IncrBy("i8", "0", 100).
+		Do(c)
+	if err != nil {
+		t.Fatalf("Do returned %v", err)
+	}
+	if len(values) != 3 || values[0] == nil || *values[0] != 0 || values[1] == nil || *values[1] != 1 || values[2] != nil {
+		t.Errorf("Do returned %v, want [0 1 nil]", values)
+	}
+	mu.Lock()
+	if want := "BITFIELD k SET i8 0 100 GET u4 #2 OVERFLOW FAIL INCRBY i8 0 100"; cmd != want {
+		t.Errorf("Do sent %q, want %q", cmd, want)
+	}
+	mu.Unlock()
+
+	// The reply must have a value for each subcommand.
+	if _, err := redis.NewBitField("k").Get("u8", "0").Do(c); err == nil {
+		t.Error("Do with mismatched reply returned nil error")
+	}
+}
+
+func TestBitFieldArgs(t *testing.T) {
+	args, err := redis.NewBitField("k").Get("i64", "#0").Get("u63", "7").Args()
+	if want := []interface{}{"k", "GET", "i64", "#0", "GET", "u63", "7"}; err != nil || !reflect.DeepEqual(args, want) {
+		t.Errorf("Args returned %v, %v, want %v", args, err, want)
+	}
+
+	for _, b := range []*redis.BitField{
+		redis.NewBitField("k").Get("u64", "0"),
+		redis.NewBitField("k").Get("i0", "0"),
+		redis.NewBitField("k").Get("x8", "0"),
+		redis.NewBitField("k").Get("i+8", "0"),
+		redis.NewBitField("k").Get("i8", "#"),
+		redis.NewBitField("k").Get("i8", "-1"),
+		redis.NewBitField("k").Overflow("clamp"),
+	} {
+		if args, err := b.Args(); err == nil {
+			t.Errorf("Args returned %v, want error", args)
+		}
+	}
+}
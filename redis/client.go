@@ -0,0 +1,126 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClientInfo describes a client connection as reported by the CLIENT LIST
+// command.
+type ClientInfo struct {
+	ID    int64
+	Addr  string
+	Name  string
+	Age   time.Duration
+	Idle  time.Duration
+	Flags string
+
+	// Fields contains all fields reported by the server for the client,
+	// including the fields above.
+	Fields map[string]string
+}
+
+// ClientList returns the client connections to the server using the CLIENT
+// LIST command.
+func ClientList(c Conn) ([]ClientInfo, error) {
+	s, err := String(c.Do("CLIENT", "LIST"))
+	if err != nil {
+		return nil, err
+	}
+	var clients []ClientInfo
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		info, err := parseClientInfo(line)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, info)
+	}
+	return clients, nil
+}
+
+func parseClientInfo(line string) (ClientInfo, error) {
+	info := ClientInfo{Fields: make(map[string]string)}
+	for _, field := range strings.Fields(line) {
+		i := strings.IndexByte(field, '=')
+		if i < 0 {
+			return ClientInfo{}, errors.New("redigo: unexpected CLIENT LIST field " + field)
+		}
+		info.Fields[field[:i]] = field[i+1:]
+	}
+	var err error
+	if s, ok := info.Fields["id"]; ok {
+		if info.ID, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return ClientInfo{}, err
+		}
+	}
+	if info.Age, err = clientSeconds(info.Fields["age"]); err != nil {
+		return ClientInfo{}, err
+	}
+	if info.Idle, err = clientSeconds(info.Fields["idle"]); err != nil {
+		return ClientInfo{}, err
+	}
+	info.Addr = info.Fields["addr"]
+	info.Name = info.Fields["name"]
+	info.Flags = info.Fields["flags"]
+	return info, nil
+}
+
+func clientSeconds(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return time.Duration(n) * time.Second, err
+}
+
+// ClientKillFilter selects the clients killed by ClientKill. A client is
+// killed if it matches all of the fields that are set.
+type ClientKillFilter struct {
+	// ID of the client.
+	ID int64
+
+	// Address of the client in ip:port format.
+	Addr string
+
+	// Type of the client: normal, master, replica or pubsub.
+	Type string
+}
+
+// ClientKill closes the client connections selected by filter using the
+// CLIENT KILL command and returns the number of clients killed.
+func ClientKill(c Conn, filter ClientKillFilter) (int, error) {
+	args := []interface{}{"KILL"}
+	if filter.ID != 0 {
+		args = append(args, "ID", filter.ID)
+	}
+	if filter.Addr != "" {
+		args = append(args, "ADDR", filter.Addr)
+	}
+	if filter.Type != "" {
+		args = append(args, "TYPE", filter.Type)
+	}
+	if len(args) == 1 {
+		return 0, errors.New("redigo: ClientKill requires a filter")
+	}
+	return Int(c.Do("CLIENT", args...))
+}
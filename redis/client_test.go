@@ -0,0 +1,102 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const clientListReply = "id=3 addr=127.0.0.1:50000 laddr=127.0.0.1:6379 fd=8 name=worker age=120 idle=5 flags=N db=0\n" +
+	"id=7 addr=127.0.0.1:50002 laddr=127.0.0.1:6379 fd=9 name= age=3 idle=0 flags=P db=0\n"
+
+func TestClientList(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(clientListReply), clientListReply)
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	clients, err := redis.ClientList(c)
+	if err != nil {
+		t.Fatalf("ClientList returned %v", err)
+	}
+	if len(clients) != 2 {
+		t.Fatalf("ClientList returned %d clients, want 2", len(clients))
+	}
+	got := clients[0]
+	got.Fields = nil
+	want := redis.ClientInfo{ID: 3, Addr: "127.0.0.1:50000", Name: "worker", Age: 120 * time.Second, Idle: 5 * time.Second, Flags: "N"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ClientList returned %+v, want %+v", got, want)
+	}
+	if fd := clients[0].Fields["fd"]; fd != "8" {
+		t.Errorf("Fields[fd] = %q, want 8", fd)
+	}
+	if id, name, flags := clients[1].ID, clients[1].Name, clients[1].Flags; id != 7 || name != "" || flags != "P" {
+		t.Errorf("ClientList returned id=%d name=%q flags=%q, want 7, \"\", P", id, name, flags)
+	}
+}
+
+func TestClientKill(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		return ":2\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		filter redis.ClientKillFilter
+		cmd    string
+	}{
+		{redis.ClientKillFilter{ID: 3}, "CLIENT KILL ID 3"},
+		{redis.ClientKillFilter{Addr: "127.0.0.1:50000", Type: "normal"}, "CLIENT KILL ADDR 127.0.0.1:50000 TYPE normal"},
+	}
+	for _, tt := range tests {
+		n, err := redis.ClientKill(c, tt.filter)
+		if n != 2 || err != nil {
+			t.Errorf("ClientKill(%+v) returned %d, %v, want 2", tt.filter, n, err)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("ClientKill(%+v) sent %q, want %q", tt.filter, cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+	if _, err := redis.ClientKill(c, redis.ClientKillFilter{}); err == nil {
+		t.Error("ClientKill with empty filter returned nil error")
+	}
+}
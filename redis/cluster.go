@@ -15,15 +15,27 @@
 package redis
 
 import (
+	"context"
 	"errors"
+	"math/rand"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // The policy to be used for selecting a slave from which to get a connection
 type SlaveSelectionPolicy int
 
 const (
-	RoundRobin SlaveSelectionPolicy = iota
+	RoundRobin       SlaveSelectionPolicy = iota
+	Weighted                              // Smooth weighted round-robin using slave weights
+	LeastConnections                      // Slave with the fewest connections in use
+
+	// Random picks a slave uniformly at random. This is a good choice for
+	// large fleets of clients, where round-robin selection started in
+	// lock-step by many processes sends correlated load to the same
+	// slaves.
+	Random
 )
 
 // Cluster represents a set of 1 Redis Master and 1 or more slaves of
@@ -33,19 +45,82 @@ const (
 // The application needs to be explicit about whether it wants a
 // master or slave connection. It is also up to the application to
 // ensure that writes don't go to the master.
+//
+// A Cluster is safe for concurrent use. Slaves can be reconfigured from
+// one goroutine while other goroutines get connections.
 type Cluster struct {
-	Policy    SlaveSelectionPolicy
-	rrCounter uint32 // Counter for deciding which slave is next in a Round-Robin policy
-	master    *Pool
-	slaves    []*Pool
+	Policy SlaveSelectionPolicy
+
+	// If FallbackToMaster is set, GetSlaveConn returns a connection to
+	// the master when the cluster has no slaves or when the pools of all
+	// slaves are exhausted. Checking for exhaustion requires acquiring
+	// the underlying connection as soon as GetSlaveConn is called.
+	FallbackToMaster bool
+
+	// If StrictTagMatching is set, GetSlaveConnMatching returns nil when
+	// no available slave has the requested tag. Otherwise it falls back
+	// to picking from all slaves.
+	StrictTagMatching bool
+
+	// DialNode is an optional application supplied function for creating
+	// the pool of a Redis Cluster node that DoSharded is redirected to.
+	// It is called without holding the cluster lock and may be called
+	// concurrently for the same address, in which case only one of the
+	// returned pools is kept and the others are closed.
+	DialNode func(addr string) (*Pool, error)
+
+	rrCounter uint32          // Counter for deciding which slave is next in a Round-Robin policy
+	sentinel  *sentinelConfig // Set for clusters created by NewClusterFromSentinel
+
+	// Source of random numbers for the Random policy. A per-cluster
+	// source avoids contention on the lock of the global source.
+	randOnce sync.Once
+	randMu   sync.Mutex
+	rand     *rand.Rand
+
+	// Current weights of the smooth weighted round-robin, parallel to
+	// rotation. Protected by wrrMu and by mu held for reading, or by mu
+	// held for writing.
+	wrrMu   sync.Mutex
+	current []int
+
+	// mu protects fields defined below.
+	mu       sync.RWMutex
+	master   *Pool
+	slaves   []*Pool
+	weights  []int      // Weight of each slave, parallel to slaves
+	tags     [][]string // Tags of each slave, parallel to slaves
+	rotation []int      // Indices of slaves that are not quarantined
+
+	// Slaves that failed the last health check and are skipped by
+	// GetSlaveConn until they recover.
+	quarantined map[*Pool]bool
+	healthStop  chan struct{}
+	healthDone  chan struct{}
+
+	// Redis Cluster nodes by address and the node owning each hash slot,
+	// as learned by DoSharded.
+	nodes map[string]*Pool
+	slots []*Pool
 }
 
-var ErrMasterAssigned = errors.New("A master has already been assigned. Use Cluster.replaceMaster to replace")
+var ErrMasterAssigned = errors.New("A master has already been assigned. Use Cluster.ReplaceMaster to replace")
 var ErrNilPool = errors.New("Given Pool(s) is not initialized")
+var ErrInvalidWeight = errors.New("Slave weight must be a positive integer")
+var ErrSlaveNotFound = errors.New("Given Pool is not a slave in the cluster")
+var ErrNoSlaves = errors.New("The cluster has no available slaves")
+var ErrNoMaster = errors.New("The cluster has no master")
+var ErrUnknownPolicy = errors.New("Unknown slave selection policy")
+var ErrHealthCheckTimeout = errors.New("Health check timed out")
+var ErrHealthCheckInterval = errors.New("Health check interval must be positive and timeout must not be negative")
+var ErrHealthCheckStarted = errors.New("The health check is already running")
+var ErrScriptNotReadOnly = errors.New("Only scripts created with NewReadOnlyScript can run on a slave")
 
 // Add a master to the cluster. Read-only applications can skip this
 // call to have only slaves in their cluster
 func (c *Cluster) AddMaster(p *Pool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.master != nil {
 		return ErrMasterAssigned
 	}
@@ -59,9 +134,18 @@ func (c *Cluster) AddMaster(p *Pool) error {
 // Close all connections to the current master and replace it with a
 // new master pool
 func (c *Cluster) replaceMaster(p *Pool) error {
+	return c.ReplaceMaster(p)
+}
+
+// Close all connections to the current master and replace it with a
+// new master pool. Use this to point the cluster at a new master after
+// a failover
+func (c *Cluster) ReplaceMaster(p *Pool) error {
 	if p == nil {
 		return ErrNilPool
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.master != nil {
 		c.master.Close()
 	}
@@ -69,67 +153,556 @@ func (c *Cluster) replaceMaster(p *Pool) error {
 	return nil
 }
 
-// Add a slave to the cluster. The same slave pool can be added
-// repeatedly to give it more weightage in the scheduling policy
+// Add a slave to the cluster with a weight of 1. The same slave pool
+// can be added repeatedly to give it more weightage in the scheduling
+// policy, but AddSlaveWithWeight is the preferred way of doing that.
+// Connections to a Redis Cluster replica must be dialed with the
+// DialReadOnly option for the replica to serve reads
 func (c *Cluster) AddSlave(p *Pool) error {
+	return c.AddSlaveWithWeight(p, 1)
+}
+
+// Add a slave to the cluster with the given weight. When Cluster.Policy
+// is Weighted, each slave gets a share of connections proportional to
+// its weight. Weights are ignored by the other policies
+func (c *Cluster) AddSlaveWithWeight(p *Pool, weight int) error {
 	if p == nil {
 		return ErrNilPool
 	}
+	if weight < 1 {
+		return ErrInvalidWeight
+	}
+	c.addSlave(p, weight, nil)
+	return nil
+}
+
+// Add a slave to the cluster with a weight of 1 and the given tags, for
+// example the region the slave is in. Use GetSlaveConnMatching to get a
+// connection from a slave with a given tag
+func (c *Cluster) AddSlaveWithTags(p *Pool, tags ...string) error {
+	if p == nil {
+		return ErrNilPool
+	}
+	c.addSlave(p, 1, append([]string(nil), tags...))
+	return nil
+}
+
+func (c *Cluster) addSlave(p *Pool, weight int, tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.slaves == nil {
 		c.slaves = make([]*Pool, 0)
 	}
 
 	c.slaves = append(c.slaves, p)
-	return nil
+	c.weights = append(c.weights, weight)
+	c.tags = append(c.tags, tags)
+	c.rebuild()
 }
 
-// Set multiple slaves in the cluster at once. Existing slave pools
-// will be closed and replaced.
+// Set multiple slaves in the cluster at once. Existing slave pools that
+// are not in the new list will be closed. Pools that are in both lists
+// are kept open along with their weight and tags, so a reconfiguration
+// can reuse the pools of unchanged slaves. New slaves have a weight of 1
+// and no tags.
 func (c *Cluster) SetSlaves(pl []*Pool) error {
+	keep := make(map[*Pool]bool, len(pl))
 	for _, pool := range pl {
 		if pool == nil {
 			return ErrNilPool
 		}
+		keep[pool] = true
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := make(map[*Pool]int, len(c.slaves))
 	if c.slaves != nil && len(c.slaves) > 0 {
-		for _, slave := range c.slaves {
-			slave.Close()
+		var gone []*Pool
+		for i, slave := range c.slaves {
+			if !keep[slave] {
+				gone = append(gone, slave)
+				delete(c.quarantined, slave)
+			} else if _, ok := old[slave]; !ok {
+				old[slave] = i
+			}
 		}
+		closePools(gone)
 		c.slaves = nil
 	}
-	c.slaves = pl
+	// Copy the list so that later changes by the caller or by AddSlave
+	// don't alias each other.
+	weights := make([]int, len(pl))
+	tags := make([][]string, len(pl))
+	for i, pool := range pl {
+		weights[i] = 1
+		if j, ok := old[pool]; ok {
+			weights[i], tags[i] = c.weights[j], c.tags[j]
+		}
+	}
+	c.slaves = append([]*Pool(nil), pl...)
+	c.weights = weights
+	c.tags = tags
+	c.rebuild()
 	return nil
 }
 
+// Remove all occurrences of a slave from the cluster and close its
+// pool. Connections already handed out from the pool are unaffected
+// until they are returned
+func (c *Cluster) RemoveSlave(p *Pool) error {
+	if p == nil {
+		return ErrNilPool
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slaves := make([]*Pool, 0, len(c.slaves))
+	weights := make([]int, 0, len(c.weights))
+	tags := make([][]string, 0, len(c.tags))
+	for i, slave := range c.slaves {
+		if slave != p {
+			slaves = append(slaves, slave)
+			weights = append(weights, c.weights[i])
+			tags = append(tags, c.tags[i])
+		}
+	}
+	if len(slaves) == len(c.slaves) {
+		return ErrSlaveNotFound
+	}
+	delete(c.quarantined, p)
+	// The round-robin counter is always reduced modulo the current
+	// number of slaves, so it stays valid after the list shrinks.
+	c.slaves = slaves
+	c.weights = weights
+	c.tags = tags
+	c.rebuild()
+	p.Close()
+	return nil
+}
+
+// Return the number of slaves in the cluster. A slave pool added more
+// than once is counted once for each time it was added
+func (c *Cluster) SlaveCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.slaves)
+}
+
+// Return true if a master has been assigned to the cluster
+func (c *Cluster) HasMaster() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.master != nil
+}
+
+// Return a copy of the list of slave pools. Changing the returned
+// slice does not change the cluster
+func (c *Cluster) Slaves() []*Pool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]*Pool(nil), c.slaves...)
+}
+
 // Get a pooled connection from the master
 func (c *Cluster) GetMasterConn() Conn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.master.Get()
 }
 
+// Get a connection from the master, honoring the cancellation and
+// deadline of ctx. If ctx is already done, its error is returned without
+// touching the pool.
+func (c *Cluster) GetMasterConnContext(ctx context.Context) (Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	master := c.master
+	c.mu.RUnlock()
+	if master == nil {
+		return nil, ErrNoMaster
+	}
+	return getContext(ctx, master)
+}
+
 // Get a pooled connection from one of the slaves as per the rotation
-// policy configured in Cluster.Policy.
+// policy configured in Cluster.Policy. If the cluster has no slaves,
+// GetSlaveConn returns nil unless Cluster.FallbackToMaster is set. Use
+// GetSlaveConnErr to find out why no connection was returned.
 func (c *Cluster) GetSlaveConn() Conn {
-	if c.slaves == nil || len(c.slaves) == 0 {
+	conn, _ := c.GetSlaveConnErr()
+	return conn
+}
+
+// Get a pooled connection from one of the slaves as per the rotation
+// policy configured in Cluster.Policy. If the cluster has no slaves,
+// GetSlaveConnErr returns ErrNoSlaves unless Cluster.FallbackToMaster
+// is set and the cluster has a master.
+func (c *Cluster) GetSlaveConnErr() (Conn, error) {
+	p, fallback, err := c.slaveCandidates()
+	if err != nil {
+		return nil, err
+	}
+	conn := p.Get()
+	// Try the fallback pools in turn while the pool is exhausted.
+	for _, p := range fallback {
+		if conn.Err() != ErrPoolExhausted {
+			break
+		}
+		conn.Close()
+		conn = p.Get()
+	}
+	return conn, nil
+}
+
+// Get a pooled connection from one of the available slaves that have
+// the given tag, in round-robin order. If no slave has the tag, a
+// connection is picked from all slaves using GetSlaveConn unless
+// Cluster.StrictTagMatching is set, in which case nil is returned.
+func (c *Cluster) GetSlaveConnMatching(tag string) Conn {
+	c.mu.RLock()
+	var matching []int
+	for _, i := range c.rotation {
+		for _, t := range c.tags[i] {
+			if t == tag {
+				matching = append(matching, i)
+				break
+			}
+		}
+	}
+	var p *Pool
+	if len(matching) > 0 {
+		i := atomic.AddUint32(&(c.rrCounter), 1)
+		p = c.slaves[matching[i%uint32(len(matching))]]
+	}
+	c.mu.RUnlock()
+	if p != nil {
+		return p.Get()
+	}
+	if c.StrictTagMatching {
 		return nil
 	}
-	if c.Policy == RoundRobin {
+	return c.GetSlaveConn()
+}
+
+// Get a connection from one of the slaves like GetSlaveConnErr,
+// honoring the cancellation and deadline of ctx. If ctx is already
+// done, its error is returned without touching any pool.
+func (c *Cluster) GetSlaveConnContext(ctx context.Context) (Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p, fallback, err := c.slaveCandidates()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := getContext(ctx, p)
+	for _, p := range fallback {
+		if err != ErrPoolExhausted {
+			break
+		}
+		conn, err = getContext(ctx, p)
+	}
+	return conn, err
+}
+
+// Get a connection from p unless ctx is already done.
+func getContext(ctx context.Context, p *Pool) (Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.GetContext(ctx)
+}
+
+// Return the slave pool to get a connection from as per Cluster.Policy.
+// If Cluster.FallbackToMaster is set, also return the pools to try in
+// order if that pool is exhausted: the other available slaves followed
+// by the master.
+func (c *Cluster) slaveCandidates() (*Pool, []*Pool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.rotation) == 0 {
+		if c.FallbackToMaster && c.master != nil {
+			return c.master, nil, nil
+		}
+		return nil, nil, ErrNoSlaves
+	}
+	r := c.pickSlave()
+	if r < 0 {
+		return nil, nil, ErrUnknownPolicy
+	}
+	p := c.slaves[c.rotation[r]]
+	if !c.FallbackToMaster || c.master == nil {
+		return p, nil, nil
+	}
+	n := len(c.rotation)
+	fallback := make([]*Pool, 0, n)
+	for i := 1; i < n; i++ {
+		fallback = append(fallback, c.slaves[c.rotation[(r+i)%n]])
+	}
+	fallback = append(fallback, c.master)
+	return p, fallback, nil
+}
+
+// Run a command on a connection from the master and return the
+// connection to the pool. ErrNoMaster is returned if the cluster has no
+// master
+func (c *Cluster) DoOnMaster(cmd string, args ...interface{}) (interface{}, error) {
+	c.mu.RLock()
+	master := c.master
+	c.mu.RUnlock()
+	if master == nil {
+		return nil, ErrNoMaster
+	}
+	conn := master.Get()
+	defer conn.Close()
+	return conn.Do(cmd, args...)
+}
+
+// Run a command on a connection from one of the slaves and return the
+// connection to the pool. The slave is picked as by GetSlaveConnErr, so
+// ErrNoSlaves is returned if no slave is available
+func (c *Cluster) DoOnSlave(cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := c.GetSlaveConnErr()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.Do(cmd, args...)
+}
+
+// Evaluate a read-only script on a connection from one of the slaves and
+// return the connection to the pool. The slave is picked as by
+// GetSlaveConnErr. ErrScriptNotReadOnly is returned for scripts that were
+// not created with NewReadOnlyScript, since slaves reject scripts that
+// may write
+func (c *Cluster) DoScriptOnSlave(s *Script, keysAndArgs ...interface{}) (interface{}, error) {
+	if !s.readOnly {
+		return nil, ErrScriptNotReadOnly
+	}
+	conn, err := c.GetSlaveConnErr()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return s.Do(conn, keysAndArgs...)
+}
+
+// Return the position in c.rotation of the slave to use as per
+// Cluster.Policy, or -1 for an unknown policy. The caller must hold c.mu
+// and ensure that c.rotation is not empty.
+func (c *Cluster) pickSlave() int {
+	n := uint32(len(c.rotation))
+	switch c.Policy {
+	case RoundRobin:
 		i := atomic.AddUint32(&(c.rrCounter), 1)
-		slaveIdx := i % uint32(len(c.slaves))
-		return c.slaves[slaveIdx].Get()
+		return int(i % n)
+	case Weighted:
+		// Smooth weighted round-robin: raise the current weight of every
+		// slave by its weight and pick the slave with the largest current
+		// weight, which is then lowered by the total of the weights.
+		c.wrrMu.Lock()
+		best, total := 0, 0
+		for r, i := range c.rotation {
+			w := c.weights[i]
+			c.current[r] += w
+			total += w
+			if c.current[r] > c.current[best] {
+				best = r
+			}
+		}
+		c.current[best] -= total
+		c.wrrMu.Unlock()
+		return best
+	case Random:
+		c.randOnce.Do(func() {
+			c.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+		})
+		c.randMu.Lock()
+		r := c.rand.Intn(int(n))
+		c.randMu.Unlock()
+		return r
+	case LeastConnections:
+		// Start the scan at the round-robin position so that ties are
+		// spread across slaves.
+		i := atomic.AddUint32(&(c.rrCounter), 1)
+		start := int(i % n)
+		best, bestCount := start, c.slaves[c.rotation[start]].inUseCount()
+		for j := 1; j < int(n); j++ {
+			k := (start + j) % int(n)
+			if count := c.slaves[c.rotation[k]].inUseCount(); count < bestCount {
+				best, bestCount = k, count
+			}
+		}
+		return best
+	}
+	return -1
+}
+
+// Compute the slaves available for selection and reset the state of the
+// Weighted policy. The caller must hold c.mu for writing. The Weighted policy
+// uses the smooth weighted round-robin algorithm so that a heavily weighted
+// slave is interleaved with the others instead of getting all of its
+// connections in a burst
+func (c *Cluster) rebuild() {
+	c.rotation = nil
+	for i, slave := range c.slaves {
+		if !c.quarantined[slave] {
+			c.rotation = append(c.rotation, i)
+		}
+	}
+	c.current = make([]int, len(c.rotation))
+}
+
+// Start a goroutine that checks the health of every slave once per
+// interval by sending PING on a connection from the slave's pool. A
+// slave that fails to reply with PONG within timeout is quarantined:
+// GetSlaveConn skips it until a later check succeeds. A timeout of zero
+// waits indefinitely for the reply. ErrHealthCheckInterval is returned
+// if interval is not positive or timeout is negative, and
+// ErrHealthCheckStarted is returned if a check is already running
+func (c *Cluster) StartHealthCheck(interval time.Duration, timeout time.Duration) error {
+	if interval <= 0 || timeout < 0 {
+		return ErrHealthCheckInterval
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.healthStop != nil {
+		return ErrHealthCheckStarted
+	}
+	c.healthStop = make(chan struct{})
+	c.healthDone = make(chan struct{})
+	go c.healthCheck(interval, timeout, c.healthStop, c.healthDone)
 	return nil
 }
 
-// Close all pools and remove everything from the cluster
+// Stop the goroutine started by StartHealthCheck and wait for it to
+// exit. Quarantined slaves stay quarantined. It is safe to call
+// StopHealthCheck more than once
+func (c *Cluster) StopHealthCheck() {
+	c.mu.Lock()
+	stop, done := c.healthStop, c.healthDone
+	c.healthStop, c.healthDone = nil, nil
+	c.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (c *Cluster) healthCheck(interval, timeout time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		healthy := make(map[*Pool]bool)
+		for _, slave := range c.Slaves() {
+			if _, seen := healthy[slave]; !seen {
+				healthy[slave] = ping(slave, timeout) == nil
+			}
+		}
+
+		c.mu.Lock()
+		changed := false
+		for slave, ok := range healthy {
+			switch {
+			case ok && c.quarantined[slave]:
+				delete(c.quarantined, slave)
+				changed = true
+			case !ok && !c.quarantined[slave] && c.hasSlave(slave):
+				if c.quarantined == nil {
+					c.quarantined = make(map[*Pool]bool)
+				}
+				c.quarantined[slave] = true
+				changed = true
+			}
+		}
+		if changed {
+			c.rebuild()
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Return true if p is still a slave of the cluster. The caller must hold
+// c.mu.
+func (c *Cluster) hasSlave(p *Pool) bool {
+	for _, slave := range c.slaves {
+		if slave == p {
+			return true
+		}
+	}
+	return false
+}
+
+var errBadPing = errors.New("Unexpected reply to PING")
+
+// Send PING on a connection from the pool and wait up to timeout for
+// the reply. If the wait times out, the connection is closed in the
+// background once the reply arrives or the connection fails.
+func ping(p *Pool, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		conn := p.Get()
+		s, err := String(conn.Do("PING"))
+		if err == nil && s != "PONG" {
+			err = errBadPing
+		}
+		conn.Close()
+		result <- err
+	}()
+	if timeout <= 0 {
+		return <-result
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-t.C:
+		return ErrHealthCheckTimeout
+	}
+}
+
+// Close each distinct pool in the list exactly once
+func closePools(pl []*Pool) {
+	closed := make(map[*Pool]bool, len(pl))
+	for _, pool := range pl {
+		if !closed[pool] {
+			closed[pool] = true
+			pool.Close()
+		}
+	}
+}
+
+// Stop the health check, close all pools and remove everything from
+// the cluster
 func (c *Cluster) TearDown() {
+	c.StopHealthCheck()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.master != nil {
 		c.master.Close()
 		c.master = nil
 	}
+	for _, node := range c.nodes {
+		node.Close()
+	}
+	c.nodes = nil
+	c.slots = nil
 	if c.slaves != nil && len(c.slaves) > 0 {
-		for _, slave := range c.slaves {
-			slave.Close()
-		}
+		closePools(c.slaves)
 		c.slaves = nil
+		c.weights = nil
+		c.tags = nil
+		c.rotation = nil
+		c.current = nil
+		c.quarantined = nil
 	}
 }
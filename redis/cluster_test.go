@@ -0,0 +1,597 @@
+// Copyright 2013 Tahir Hashmi, Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slavePool returns the pool that handed out a connection from the cluster.
+func slavePool(c Conn) *Pool {
+	if c == nil {
+		return nil
+	}
+	return c.(*pooledConnection).p
+}
+
+func TestClusterWeighted(t *testing.T) {
+	d := dialer{t: t}
+	p1 := &Pool{Dial: d.dial}
+	p2 := &Pool{Dial: d.dial}
+	c := &Cluster{Policy: Weighted}
+	if err := c.AddSlaveWithWeight(p1, 3); err != nil {
+		t.Fatalf("AddSlaveWithWeight(p1, 3) returned %v", err)
+	}
+	if err := c.AddSlaveWithWeight(p2, 1); err != nil {
+		t.Fatalf("AddSlaveWithWeight(p2, 1) returned %v", err)
+	}
+	if err := c.AddSlaveWithWeight(p2, 0); err != ErrInvalidWeight {
+		t.Errorf("AddSlaveWithWeight(p2, 0) returned %v, want %v", err, ErrInvalidWeight)
+	}
+
+	counts := make(map[*Pool]int)
+	var last *Pool
+	run := 0
+	for i := 0; i < 400; i++ {
+		p := slavePool(c.GetSlaveConn())
+		counts[p] += 1
+		if p == last {
+			run += 1
+		} else {
+			run = 1
+		}
+		if run > 3 {
+			t.Fatalf("p1 picked more than 3 times in a row")
+		}
+		last = p
+	}
+	if counts[p1] != 300 || counts[p2] != 100 {
+		t.Errorf("counts = %d, %d, want 300, 100", counts[p1], counts[p2])
+	}
+
+	// Large weights do not cost memory or time in proportion to the
+	// weight.
+	p3 := &Pool{Dial: d.dial}
+	if err := c.AddSlaveWithWeight(p3, 1<<30); err != nil {
+		t.Fatalf("AddSlaveWithWeight(p3, 1<<30) returned %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if p := slavePool(c.GetSlaveConn()); p != p3 {
+			t.Fatalf("pick %d is not the heavily weighted slave", i)
+		}
+	}
+}
+
+func TestClusterTearDownWeighted(t *testing.T) {
+	d := dialer{t: t}
+	p1 := &Pool{MaxIdle: 1, Dial: d.dial}
+	p2 := &Pool{MaxIdle: 1, Dial: d.dial}
+	c := &Cluster{Policy: Weighted}
+	c.AddSlave(p1)
+	c.AddSlave(p1)
+	c.AddSlaveWithWeight(p2, 2)
+
+	for i := 0; i < 4; i++ {
+		conn := c.GetSlaveConn()
+		conn.Do("PING")
+		conn.Close()
+	}
+	c.TearDown()
+	if !p1.closed || !p2.closed {
+		t.Errorf("slave pools not closed by TearDown")
+	}
+	if d.open != 0 {
+		t.Errorf("open=%d, want 0", d.open)
+	}
+	if conn := c.GetSlaveConn(); conn != nil {
+		t.Errorf("GetSlaveConn() after TearDown = %v, want nil", conn)
+	}
+}
+
+func TestClusterConcurrentReconfigure(t *testing.T) {
+	pools := make([]*Pool, 4)
+	for i := range pools {
+		pools[i] = &Pool{Dial: func() (Conn, error) { return &fakeConn{open: new(int)}, nil }}
+	}
+	c := &Cluster{}
+	c.AddMaster(pools[0])
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			c.SetSlaves(pools[i%3 : i%3+1+i%2])
+			c.AddSlave(pools[3])
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if conn := c.GetSlaveConn(); conn != nil {
+			conn.Close()
+		}
+		c.GetMasterConn().Close()
+	}
+	<-done
+}
+
+func TestClusterRemoveSlave(t *testing.T) {
+	d := dialer{t: t}
+	p1 := &Pool{Dial: d.dial}
+	p2 := &Pool{Dial: d.dial}
+	p3 := &Pool{Dial: d.dial}
+	for _, policy := range []SlaveSelectionPolicy{RoundRobin, Weighted} {
+		c := &Cluster{Policy: policy}
+		c.AddSlave(p1)
+		c.AddSlaveWithWeight(p2, 2)
+		c.AddSlave(p1)
+		c.AddSlave(p3)
+
+		// Advance the counter past the length of the shrunk list.
+		for i := 0; i < 3; i++ {
+			c.GetSlaveConn()
+		}
+		if err := c.RemoveSlave(p1); err != nil {
+			t.Fatalf("RemoveSlave(p1) returned %v", err)
+		}
+		if !p1.closed {
+			t.Errorf("RemoveSlave(p1) did not close the pool")
+		}
+		p1.closed = false
+		if err := c.RemoveSlave(p1); err != ErrSlaveNotFound {
+			t.Errorf("RemoveSlave(p1) returned %v, want %v", err, ErrSlaveNotFound)
+		}
+		for i := 0; i < 10; i++ {
+			if p := slavePool(c.GetSlaveConn()); p != p2 && p != p3 {
+				t.Fatalf("GetSlaveConn() returned connection from %p, want p2 or p3", p)
+			}
+		}
+	}
+}
+
+func TestClusterFallbackToMaster(t *testing.T) {
+	d := dialer{t: t}
+	master := &Pool{Dial: d.dial}
+
+	c := &Cluster{FallbackToMaster: true}
+	if conn := c.GetSlaveConn(); conn != nil {
+		t.Errorf("GetSlaveConn() on empty cluster = %v, want nil", conn)
+	}
+
+	c.AddMaster(master)
+	if p := slavePool(c.GetSlaveConn()); p != master {
+		t.Errorf("GetSlaveConn() with no slaves used %p, want master %p", p, master)
+	}
+
+	c.FallbackToMaster = false
+	if conn := c.GetSlaveConn(); conn != nil {
+		t.Errorf("GetSlaveConn() without fallback = %v, want nil", conn)
+	}
+
+	c.FallbackToMaster = true
+	slave := &Pool{MaxActive: 1, Dial: d.dial}
+	c.AddSlave(slave)
+	busy := c.GetSlaveConn()
+	if p := slavePool(busy); p != slave {
+		t.Fatalf("GetSlaveConn() used %p, want slave %p", p, slave)
+	}
+	if p := slavePool(c.GetSlaveConn()); p != master {
+		t.Errorf("GetSlaveConn() with exhausted slave used %p, want master %p", p, master)
+	}
+	busy.Close()
+	if p := slavePool(c.GetSlaveConn()); p != slave {
+		t.Errorf("GetSlaveConn() after release used %p, want slave %p", p, slave)
+	}
+}
+
+func TestClusterGetSlaveConnErr(t *testing.T) {
+	d := dialer{t: t}
+	c := &Cluster{}
+	if conn, err := c.GetSlaveConnErr(); conn != nil || err != ErrNoSlaves {
+		t.Errorf("GetSlaveConnErr() = %v, %v, want nil, %v", conn, err, ErrNoSlaves)
+	}
+	slave := &Pool{Dial: d.dial}
+	c.AddSlave(slave)
+	conn, err := c.GetSlaveConnErr()
+	if err != nil {
+		t.Fatalf("GetSlaveConnErr() returned %v", err)
+	}
+	if p := slavePool(conn); p != slave {
+		t.Errorf("GetSlaveConnErr() used %p, want %p", p, slave)
+	}
+	c.Policy = SlaveSelectionPolicy(-1)
+	if _, err := c.GetSlaveConnErr(); err != ErrUnknownPolicy {
+		t.Errorf("GetSlaveConnErr() returned %v, want %v", err, ErrUnknownPolicy)
+	}
+}
+
+func TestClusterLeastConnections(t *testing.T) {
+	d := dialer{t: t}
+	p1 := &Pool{MaxIdle: 2, Dial: d.dial}
+	p2 := &Pool{MaxIdle: 2, Dial: d.dial}
+	c := &Cluster{Policy: LeastConnections}
+	c.SetSlaves([]*Pool{p1, p2})
+
+	// An idle connection in p1 does not count as load.
+	idle := p1.Get()
+	idle.Do("PING")
+	idle.Close()
+
+	busy := p2.Get()
+	busy.Do("PING")
+	for i := 0; i < 4; i++ {
+		if p := slavePool(c.GetSlaveConn()); p != p1 {
+			t.Fatalf("GetSlaveConn() used %p, want least loaded %p", p, p1)
+		}
+	}
+	busy.Close()
+
+	counts := make(map[*Pool]int)
+	for i := 0; i < 10; i++ {
+		counts[slavePool(c.GetSlaveConn())] += 1
+	}
+	if counts[p1] != 5 || counts[p2] != 5 {
+		t.Errorf("counts with equal load = %d, %d, want 5, 5", counts[p1], counts[p2])
+	}
+}
+
+func TestClusterTopology(t *testing.T) {
+	d := dialer{t: t}
+	p1 := &Pool{Dial: d.dial}
+	p2 := &Pool{Dial: d.dial}
+	c := &Cluster{}
+	if c.HasMaster() || c.SlaveCount() != 0 || len(c.Slaves()) != 0 {
+		t.Errorf("empty cluster has master=%v, slaves=%d", c.HasMaster(), c.SlaveCount())
+	}
+	c.AddMaster(p1)
+	c.AddSlave(p1)
+	c.AddSlave(p2)
+	if !c.HasMaster() {
+		t.Errorf("HasMaster() = false, want true")
+	}
+	if n := c.SlaveCount(); n != 2 {
+		t.Errorf("SlaveCount() = %d, want 2", n)
+	}
+	slaves := c.Slaves()
+	if len(slaves) != 2 || slaves[0] != p1 || slaves[1] != p2 {
+		t.Fatalf("Slaves() = %v, want [%p %p]", slaves, p1, p2)
+	}
+	slaves[0] = nil
+	if s := c.Slaves(); s[0] != p1 {
+		t.Errorf("changing result of Slaves() changed the cluster")
+	}
+}
+
+// healthConn replies to PING if its server is up and blocks while its
+// server hangs.
+type healthConn struct {
+	fakeConn
+	up   *int32
+	hang chan struct{}
+}
+
+func (c *healthConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if c.hang != nil {
+		<-c.hang
+	}
+	if atomic.LoadInt32(c.up) == 0 {
+		return nil, errors.New("connection refused")
+	}
+	return "PONG", nil
+}
+
+func healthPool(up *int32, hang chan struct{}) *Pool {
+	return &Pool{MaxIdle: 1, Dial: func() (Conn, error) {
+		return &healthConn{fakeConn: fakeConn{open: new(int)}, up: up, hang: hang}, nil
+	}}
+}
+
+// waitRotation waits for the number of slaves available to GetSlaveConn to
+// become n.
+func waitRotation(t *testing.T, c *Cluster, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.RLock()
+		m := len(c.rotation)
+		c.mu.RUnlock()
+		if m == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("available slaves = %d, want %d", m, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClusterHealthCheck(t *testing.T) {
+	up1, up2 := int32(1), int32(0)
+	hang := make(chan struct{})
+	defer close(hang)
+	p1, p2, p3 := healthPool(&up1, nil), healthPool(&up2, nil), healthPool(&up1, hang)
+	c := &Cluster{}
+	c.SetSlaves([]*Pool{p1, p2, p3})
+
+	if err := c.StartHealthCheck(0, 20*time.Millisecond); err != ErrHealthCheckInterval {
+		t.Fatalf("StartHealthCheck(0) returned %v, want %v", err, ErrHealthCheckInterval)
+	}
+	if err := c.StartHealthCheck(5*time.Millisecond, 20*time.Millisecond); err != nil {
+		t.Fatalf("StartHealthCheck returned %v", err)
+	}
+	if err := c.StartHealthCheck(5*time.Millisecond, 20*time.Millisecond); err != ErrHealthCheckStarted {
+		t.Fatalf("second StartHealthCheck returned %v, want %v", err, ErrHealthCheckStarted)
+	}
+	waitRotation(t, c, 1)
+	for i := 0; i < 4; i++ {
+		if p := slavePool(c.GetSlaveConn()); p != p1 {
+			t.Fatalf("GetSlaveConn() used %p, want healthy %p", p, p1)
+		}
+	}
+
+	atomic.StoreInt32(&up2, 1)
+	waitRotation(t, c, 2)
+	atomic.StoreInt32(&up1, 0)
+	waitRotation(t, c, 1)
+	if p := slavePool(c.GetSlaveConn()); p != p2 {
+		t.Errorf("GetSlaveConn() used %p, want recovered %p", p, p2)
+	}
+
+	c.StopHealthCheck()
+	c.StopHealthCheck()
+	atomic.StoreInt32(&up1, 1)
+	time.Sleep(20 * time.Millisecond)
+	waitRotation(t, c, 1)
+	c.TearDown()
+}
+
+func TestClusterReplaceMaster(t *testing.T) {
+	d := dialer{t: t}
+	p1 := &Pool{Dial: d.dial}
+	p2 := &Pool{Dial: d.dial}
+	c := &Cluster{}
+	if err := c.ReplaceMaster(nil); err != ErrNilPool {
+		t.Errorf("ReplaceMaster(nil) returned %v, want %v", err, ErrNilPool)
+	}
+	c.AddMaster(p1)
+	if err := c.AddMaster(p2); err != ErrMasterAssigned {
+		t.Errorf("AddMaster(p2) returned %v, want %v", err, ErrMasterAssigned)
+	}
+	if err := c.ReplaceMaster(p2); err != nil {
+		t.Fatalf("ReplaceMaster(p2) returned %v", err)
+	}
+	if !p1.closed {
+		t.Errorf("old master not closed")
+	}
+	if p := slavePool(c.GetMasterConn()); p != p2 {
+		t.Errorf("GetMasterConn() used %p, want %p", p, p2)
+	}
+}
+
+func TestClusterContext(t *testing.T) {
+	d := dialer{t: t}
+	master := &Pool{Dial: d.dial}
+	slave := &Pool{MaxActive: 1, Dial: d.dial}
+	c := &Cluster{}
+
+	ctx := context.Background()
+	if _, err := c.GetMasterConnContext(ctx); err != ErrNoMaster {
+		t.Errorf("GetMasterConnContext() returned %v, want %v", err, ErrNoMaster)
+	}
+	if _, err := c.GetSlaveConnContext(ctx); err != ErrNoSlaves {
+		t.Errorf("GetSlaveConnContext() returned %v, want %v", err, ErrNoSlaves)
+	}
+	c.AddMaster(master)
+	c.AddSlave(slave)
+
+	conn, err := c.GetSlaveConnContext(ctx)
+	if err != nil {
+		t.Fatalf("GetSlaveConnContext() returned %v", err)
+	}
+	d.check("after get", slave, 1, 1)
+	if _, err := c.GetSlaveConnContext(ctx); err != ErrPoolExhausted {
+		t.Errorf("GetSlaveConnContext() on exhausted slave returned %v, want %v", err, ErrPoolExhausted)
+	}
+	c.FallbackToMaster = true
+	if conn, err := c.GetSlaveConnContext(ctx); err != nil || slavePool(conn) != master {
+		t.Errorf("GetSlaveConnContext() with fallback = %v, %v, want master connection", conn, err)
+	}
+	conn.Close()
+
+	dialed := d.dialed
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.GetMasterConnContext(cancelled); err != context.Canceled {
+		t.Errorf("GetMasterConnContext() returned %v, want %v", err, context.Canceled)
+	}
+	if _, err := c.GetSlaveConnContext(cancelled); err != context.Canceled {
+		t.Errorf("GetSlaveConnContext() returned %v, want %v", err, context.Canceled)
+	}
+	if d.dialed != dialed {
+		t.Errorf("cancelled get dialed a connection")
+	}
+}
+
+func TestClusterRandom(t *testing.T) {
+	d := dialer{t: t}
+	pools := []*Pool{{Dial: d.dial}, {Dial: d.dial}, {Dial: d.dial}, {Dial: d.dial}}
+	c := &Cluster{Policy: Random}
+	c.SetSlaves(pools)
+
+	const n = 40000
+	counts := make(map[*Pool]int)
+	for i := 0; i < n; i++ {
+		counts[slavePool(c.GetSlaveConn())] += 1
+	}
+	// Each count is binomial with mean 10000 and standard deviation about
+	// 87, so a 5% tolerance is more than 5 standard deviations.
+	want := n / len(pools)
+	for i, p := range pools {
+		if got := counts[p]; got < want*95/100 || got > want*105/100 {
+			t.Errorf("slave %d picked %d times, want about %d", i, got, want)
+		}
+	}
+}
+
+func TestClusterTags(t *testing.T) {
+	d := dialer{t: t}
+	east1 := &Pool{Dial: d.dial}
+	east2 := &Pool{Dial: d.dial}
+	west := &Pool{Dial: d.dial}
+	c := &Cluster{}
+	c.AddSlaveWithTags(east1, "us-east", "ssd")
+	c.AddSlave(west)
+	c.AddSlaveWithTags(east2, "us-east")
+
+	counts := make(map[*Pool]int)
+	for i := 0; i < 10; i++ {
+		counts[slavePool(c.GetSlaveConnMatching("us-east"))] += 1
+	}
+	if counts[east1] != 5 || counts[east2] != 5 {
+		t.Errorf("us-east counts = %d, %d, want 5, 5", counts[east1], counts[east2])
+	}
+	if p := slavePool(c.GetSlaveConnMatching("ssd")); p != east1 {
+		t.Errorf("GetSlaveConnMatching(ssd) used %p, want %p", p, east1)
+	}
+	if conn := c.GetSlaveConnMatching("eu"); conn == nil {
+		t.Errorf("GetSlaveConnMatching(eu) = nil, want fallback connection")
+	}
+	c.StrictTagMatching = true
+	if conn := c.GetSlaveConnMatching("eu"); conn != nil {
+		t.Errorf("strict GetSlaveConnMatching(eu) = %v, want nil", conn)
+	}
+
+	// Removing a slave keeps the remaining tags attached to the right pools.
+	c.RemoveSlave(east1)
+	for i := 0; i < 4; i++ {
+		if p := slavePool(c.GetSlaveConnMatching("us-east")); p != east2 {
+			t.Fatalf("GetSlaveConnMatching(us-east) after remove used %p, want %p", p, east2)
+		}
+	}
+	if conn := c.GetSlaveConnMatching("ssd"); conn != nil {
+		t.Errorf("GetSlaveConnMatching(ssd) after remove = %v, want nil", conn)
+	}
+
+	// SetSlaves keeps the tags of the slaves that stay and adds new
+	// slaves without tags.
+	west2 := &Pool{Dial: d.dial}
+	c.SetSlaves([]*Pool{west2, east2})
+	for i := 0; i < 4; i++ {
+		if p := slavePool(c.GetSlaveConnMatching("us-east")); p != east2 {
+			t.Fatalf("GetSlaveConnMatching(us-east) after SetSlaves used %p, want %p", p, east2)
+		}
+	}
+}
+
+func TestClusterDo(t *testing.T) {
+	d := dialer{t: t}
+	master := &Pool{MaxIdle: 1, Dial: d.dial}
+	slave := &Pool{MaxIdle: 1, Dial: d.dial}
+	c := &Cluster{}
+	if _, err := c.DoOnMaster("PING"); err != ErrNoMaster {
+		t.Errorf("DoOnMaster() returned %v, want %v", err, ErrNoMaster)
+	}
+	if _, err := c.DoOnSlave("PING"); err != ErrNoSlaves {
+		t.Errorf("DoOnSlave() returned %v, want %v", err, ErrNoSlaves)
+	}
+	c.AddMaster(master)
+	c.AddSlave(slave)
+	for i := 0; i < 3; i++ {
+		if _, err := c.DoOnMaster("PING"); err != nil {
+			t.Errorf("DoOnMaster() returned %v", err)
+		}
+		if _, err := c.DoOnSlave("PING"); err != nil {
+			t.Errorf("DoOnSlave() returned %v", err)
+		}
+	}
+	if n := master.inUseCount() + slave.inUseCount(); n != 0 {
+		t.Errorf("%d connections not returned to the pools", n)
+	}
+	if d.dialed != 2 {
+		t.Errorf("dialed=%d, want one connection per pool", d.dialed)
+	}
+
+	// A command that breaks the connection still releases it.
+	if _, err := c.DoOnSlave("ERR", errors.New("broken")); err != nil {
+		t.Errorf("DoOnSlave(ERR) returned %v", err)
+	}
+	if n := slave.ActiveCount(); n != 0 {
+		t.Errorf("broken connection not released, active=%d", n)
+	}
+}
+
+func TestClusterDoScriptOnSlave(t *testing.T) {
+	var cmds []string
+	open := 0
+	slave := &Pool{MaxIdle: 1, Dial: func() (Conn, error) {
+		open += 1
+		return &recordConn{fakeConn: fakeConn{open: &open}, cmds: &cmds}, nil
+	}}
+	c := &Cluster{}
+	c.AddSlave(slave)
+
+	if _, err := c.DoScriptOnSlave(NewScript(0, "return 1")); err != ErrScriptNotReadOnly {
+		t.Errorf("DoScriptOnSlave(script) returned %v, want %v", err, ErrScriptNotReadOnly)
+	}
+	if len(cmds) != 0 {
+		t.Errorf("DoScriptOnSlave(script) sent %v", cmds)
+	}
+
+	s := NewReadOnlyScript(1, "return 1")
+	if _, err := c.DoScriptOnSlave(s, "key"); err != nil {
+		t.Errorf("DoScriptOnSlave(read-only script) returned %v", err)
+	}
+	if want := "EVALSHA_RO " + s.hash + " 1 key"; len(cmds) != 1 || cmds[0] != want {
+		t.Errorf("DoScriptOnSlave(read-only script) sent %q, want %q", cmds, want)
+	}
+	if n := slave.ActiveCount(); n != 1 || slave.inUseCount() != 0 {
+		t.Errorf("connection not returned to the pool")
+	}
+}
+
+func TestClusterSetSlavesOverlap(t *testing.T) {
+	d := dialer{t: t}
+	p1 := &Pool{MaxIdle: 1, Dial: d.dial}
+	p2 := &Pool{MaxIdle: 1, Dial: d.dial}
+	p3 := &Pool{MaxIdle: 1, Dial: d.dial}
+	c := &Cluster{}
+	c.AddSlave(p1)
+	c.AddSlaveWithWeight(p2, 3)
+	if err := c.SetSlaves([]*Pool{p2, p3}); err != nil {
+		t.Fatalf("SetSlaves returned %v", err)
+	}
+	// The kept slave keeps its weight.
+	c.mu.RLock()
+	weights := append([]int(nil), c.weights...)
+	c.mu.RUnlock()
+	if len(weights) != 2 || weights[0] != 3 || weights[1] != 1 {
+		t.Errorf("weights after SetSlaves = %v, want [3 1]", weights)
+	}
+	if !p1.closed {
+		t.Errorf("dropped slave not closed")
+	}
+	if p2.closed {
+		t.Fatalf("shared slave closed")
+	}
+	conn := p2.Get()
+	if _, err := conn.Do("PING"); err != nil {
+		t.Errorf("Do on shared slave returned %v", err)
+	}
+	conn.Close()
+	if n := c.SlaveCount(); n != 2 {
+		t.Errorf("SlaveCount() = %d, want 2", n)
+	}
+}
@@ -17,11 +17,17 @@ package redis
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
+	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -36,8 +42,9 @@ type conn struct {
 	conn    net.Conn
 
 	// Read
-	readTimeout time.Duration
-	br          *bufio.Reader
+	readTimeout  time.Duration
+	br           *bufio.Reader
+	maxReplySize int
 
 	// Write
 	writeTimeout time.Duration
@@ -49,15 +56,454 @@ type conn struct {
 
 	// Scratch space for formatting integers and floats.
 	numScratch [40]byte
+
+	// Observer and the names of commands sent and not received yet. The
+	// names are recorded only when observer is set.
+	observer DialObserver
+	sent     []string
+
+	trace TraceHooks
+
+	// Protocol version selected by Dial and the handler for RESP3 push
+	// replies. A push is returned as a reply if onPush is nil or returns
+	// false.
+	protocol int
+	onPush   func([]interface{}) bool
+}
+
+// DialOption specifies an option for dialing a Redis server.
+type DialOption struct {
+	f func(*dialOptions)
+}
+
+type dialOptions struct {
+	username     string
+	password     string
+	credentials  func() (username, password string, err error)
+	useTLS       bool
+	skipVerify   bool
+	tlsConfig    *tls.Config
+	protocol     int
+	clientName   string
+	db           int
+	dbFromURL    bool
+	readOnly     bool
+	keepAlive    time.Duration
+	noDelay      bool
+	observer     DialObserver
+	trace        TraceHooks
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	writeBufSize int
+	maxReplySize int
+}
+
+// DialObserver is notified of the commands executed on a connection.
+type DialObserver interface {
+	// OnCommand is called when the reply to a command is read. The
+	// duration is measured from the start of the Do or Receive call that
+	// read the reply, and err is the error returned for the command.
+	// Replies that do not match a sent command, such as messages received
+	// in subscribe mode, have an empty command name.
+	OnCommand(cmd string, dur time.Duration, err error)
+}
+
+// TraceHooks is called around each command executed with DoContext, so that
+// an application can bridge commands to a tracing system. BeforeCommand
+// returns the context used for the command, for example a context carrying a
+// new span. AfterCommand is called with that context when the command
+// completes.
+type TraceHooks interface {
+	BeforeCommand(ctx context.Context, cmd string, args []interface{}) context.Context
+	AfterCommand(ctx context.Context, cmd string, err error, dur time.Duration)
+}
+
+// DialPassword specifies the password to use when connecting to the Redis
+// server.
+func DialPassword(password string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.password = password
+	}}
+}
+
+// DialUsername specifies the username to use when connecting to a Redis
+// server with ACLs. The username is sent only when a password is also
+// specified. When the username is empty, the single argument form of AUTH
+// is used.
+func DialUsername(username string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.username = username
+	}}
+}
+
+// DialCredentialsProvider specifies a function that returns the username and
+// password to use when connecting to the Redis server. The function is
+// called by each Dial, so that a pool with a Dial function using this option
+// authenticates each new connection with the current credentials, such as a
+// short-lived token. The credentials replace those given with DialUsername
+// and DialPassword. Existing connections are not authenticated again when the
+// credentials change; set the pool's MaxConnLifetime to replace connections
+// before their credentials expire.
+func DialCredentialsProvider(fn func() (username, password string, err error)) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.credentials = fn
+	}}
+}
+
+// DialUseTLS specifies whether TLS should be used when connecting to the
+// server.
+func DialUseTLS(useTLS bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.useTLS = useTLS
+	}}
+}
+
+// DialTLSConfig specifies the config to use when a TLS connection is dialed.
+// The option has no effect unless DialUseTLS(true) is also given. If the
+// config does not set ServerName, then the host of the address is used.
+func DialTLSConfig(c *tls.Config) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.tlsConfig = c
+	}}
+}
+
+// DialTLSSkipVerify disables server name verification when connecting over
+// TLS. Use this option only with servers that have self-signed certificates
+// in development setups.
+func DialTLSSkipVerify(skip bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.skipVerify = skip
+	}}
+}
+
+// DialReadTimeout specifies the timeout for reading a reply. The timeout
+// applies to each Do and Receive call on the connection. A read that times
+// out breaks the connection, so commands that block on the server, such as
+// BRPOP, should be run with DoWithTimeout and a timeout longer than the time
+// the command blocks, or zero for no timeout.
+func DialReadTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.readTimeout = d
+	}}
+}
+
+// DialWriteTimeout specifies the timeout for writing a command. The timeout
+// applies to each Send, Flush and Do call on the connection.
+func DialWriteTimeout(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.writeTimeout = d
+	}}
+}
+
+// DialWriteBufferSize specifies the size of the buffer used for writing
+// commands to the connection. Commands written with Send are held in the
+// buffer until the buffer is full or Flush is called, so a larger buffer
+// sends a long pipeline in fewer writes to the network. If n is zero or
+// negative, the default size of the bufio package is used.
+func DialWriteBufferSize(n int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.writeBufSize = n
+	}}
+}
+
+// DialMaxReplySize specifies the maximum length of a bulk string reply and
+// the maximum number of elements in an array, set, map or push reply. A reply
+// that exceeds the limit is not read and the connection is closed with an
+// error, so that a bad length received from the server cannot exhaust the
+// memory of the application. If n is zero or negative, the size of replies
+// is not limited.
+func DialMaxReplySize(n int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.maxReplySize = n
+	}}
+}
+
+// DialKeepAlive specifies the period of TCP keep-alive probes on the
+// connection. A zero or negative duration disables keep-alives. The default
+// is 5 minutes. The option is ignored for connections that are not TCP.
+func DialKeepAlive(d time.Duration) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.keepAlive = d
+	}}
 }
 
-// Dial connects to the Redis server at the given network and address.
-func Dial(network, address string) (Conn, error) {
-	c, err := net.Dial(network, address)
+// DialTCPNoDelay specifies whether Nagle's algorithm is disabled on the
+// connection. The default is true, so that small writes are sent without
+// delay. The option is ignored for connections that are not TCP.
+func DialTCPNoDelay(noDelay bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.noDelay = noDelay
+	}}
+}
+
+// DialObserverHook specifies an observer that is notified after each
+// command executed on the connection. Commands sent by Dial to set up the
+// connection are not observed.
+func DialObserverHook(obs DialObserver) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.observer = obs
+	}}
+}
+
+// DialTraceHooks specifies hooks that are called around each command
+// executed on the connection with DoContext. Commands sent by Dial to set up
+// the connection are not traced.
+func DialTraceHooks(hooks TraceHooks) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.trace = hooks
+	}}
+}
+
+// DialDatabase specifies the database to select with the SELECT command
+// after the connection is authenticated. The default is database 0, which is
+// selected without sending a command.
+func DialDatabase(db int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.db = db
+		do.dbFromURL = false
+	}}
+}
+
+// DialReadOnly specifies whether the READONLY command is sent after the
+// connection is set up. A Redis Cluster replica serves reads for the slots
+// of its master only on connections in read-only mode, so dial connections
+// to cluster replicas used as slaves of a Cluster with DialReadOnly(true).
+// Dial fails if the server rejects the command.
+func DialReadOnly(readOnly bool) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.readOnly = readOnly
+	}}
+}
+
+// DialClientName specifies a name for the connection that is set with the
+// CLIENT SETNAME command after the connection is authenticated. The name is
+// reported by CLIENT LIST. Dial fails if the server rejects the name. To
+// distinguish the connections of a pool, include a sequence number in the
+// name in the pool's Dial function.
+func DialClientName(name string) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.clientName = name
+	}}
+}
+
+// DialProtocol specifies the version of the Redis protocol to use. Version 3
+// requires Redis 6 or later and is selected with the HELLO command after the
+// connection is authenticated. The default is version 2.
+func DialProtocol(version int) DialOption {
+	return DialOption{func(do *dialOptions) {
+		do.protocol = version
+	}}
+}
+
+// Dial connects to the Redis server at the given network and address using
+// the specified options.
+func Dial(network, address string, options ...DialOption) (Conn, error) {
+	do := dialOptions{
+		keepAlive: 5 * time.Minute,
+		noDelay:   true,
+	}
+	for _, option := range options {
+		option.f(&do)
+	}
+	switch do.protocol {
+	case 0, 2, 3:
+	default:
+		return nil, fmt.Errorf("redigo: unsupported protocol version %d", do.protocol)
+	}
+
+	if do.credentials != nil {
+		username, password, err := do.credentials()
+		if err != nil {
+			return nil, err
+		}
+		do.username, do.password = username, password
+	}
+
+	netConn, err := net.Dial(network, address)
 	if err != nil {
 		return nil, err
 	}
-	return NewConn(c, 0, 0), nil
+
+	if tcpConn, ok := netConn.(*net.TCPConn); ok {
+		if err := setTCPOptions(tcpConn, &do); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if do.useTLS {
+		var tlsConfig *tls.Config
+		if do.tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		} else {
+			tlsConfig = do.tlsConfig.Clone()
+		}
+		if do.skipVerify {
+			tlsConfig.InsecureSkipVerify = true
+		}
+		if tlsConfig.ServerName == "" {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				netConn.Close()
+				return nil, err
+			}
+			tlsConfig.ServerName = host
+		}
+
+		tlsConn := tls.Client(netConn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		netConn = tlsConn
+	}
+
+	c := NewConn(netConn, do.readTimeout, do.writeTimeout)
+	if do.writeBufSize > 0 {
+		c.(*conn).bw = bufio.NewWriterSize(netConn, do.writeBufSize)
+	}
+	c.(*conn).maxReplySize = do.maxReplySize
+
+	if do.password != "" {
+		authArgs := []interface{}{do.password}
+		if do.username != "" {
+			authArgs = []interface{}{do.username, do.password}
+		}
+		if _, err := c.Do("AUTH", authArgs...); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if do.db != 0 {
+		if _, err := c.Do("SELECT", do.db); err != nil {
+			netConn.Close()
+			if _, ok := err.(Error); ok && do.dbFromURL {
+				return nil, fmt.Errorf("redigo: invalid database %d in URL: %v", do.db, err)
+			}
+			return nil, err
+		}
+	}
+
+	if do.protocol == 3 {
+		if _, err := c.Do("HELLO", 3); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	if do.readOnly {
+		if _, err := c.Do("READONLY"); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("redigo: READONLY rejected: %v", err)
+		}
+	}
+
+	if do.clientName != "" {
+		if _, err := c.Do("CLIENT", "SETNAME", do.clientName); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	c.(*conn).observer = do.observer
+	c.(*conn).trace = do.trace
+	c.(*conn).protocol = do.protocol
+	return c, nil
+}
+
+func setTCPOptions(c *net.TCPConn, do *dialOptions) error {
+	if do.keepAlive > 0 {
+		if err := c.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if err := c.SetKeepAlivePeriod(do.keepAlive); err != nil {
+			return err
+		}
+	} else if err := c.SetKeepAlive(false); err != nil {
+		return err
+	}
+	return c.SetNoDelay(do.noDelay)
+}
+
+// DialURL connects to a Redis server at the given URL using the Redis URI
+// scheme. URLs should follow the draft IANA specification for the scheme
+// (https://www.iana.org/assignments/uri-schemes/prov/redis). The rediss
+// scheme connects using TLS and verifies the server name against the host
+// of the URL. The password and the optional username in the user
+// information of the URL are used to authenticate. The database is selected
+// from the path of the URL, as in redis://host:6379/3, or from the db query
+// parameter, as in redis://host:6379?db=3. DialURL returns an error if the
+// database is not a number, is negative or is rejected by the server.
+func DialURL(rawurl string, options ...DialOption) (Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		options = append([]DialOption{DialUseTLS(true)}, options...)
+	default:
+		return nil, fmt.Errorf("redigo: invalid URL scheme: %s", u.Scheme)
+	}
+
+	// As per the IANA draft spec, the host defaults to localhost and
+	// the port defaults to 6379.
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		// assume port is missing
+		host = u.Host
+		port = "6379"
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	address := net.JoinHostPort(host, port)
+
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options = append([]DialOption{DialUsername(u.User.Username()), DialPassword(password)}, options...)
+		}
+	}
+
+	db, err := urlDatabase(u)
+	if err != nil {
+		return nil, err
+	}
+	if db != 0 {
+		// The server rejects databases beyond its configured number of
+		// databases. Dial reports that error as an invalid URL database.
+		options = append([]DialOption{{func(do *dialOptions) {
+			do.db = db
+			do.dbFromURL = true
+		}}}, options...)
+	}
+
+	return Dial("tcp", address, options...)
+}
+
+// urlDatabase returns the database specified by the path or the db query
+// parameter of a Redis URL.
+func urlDatabase(u *url.URL) (int, error) {
+	path := strings.TrimPrefix(u.Path, "/")
+	query := u.Query().Get("db")
+	if path != "" && query != "" && path != query {
+		return 0, fmt.Errorf("redigo: URL path database %q does not match db query parameter %q", path, query)
+	}
+	s := path
+	if s == "" {
+		s = query
+	}
+	if s == "" {
+		return 0, nil
+	}
+	db, err := strconv.Atoi(s)
+	if err != nil || db < 0 {
+		return 0, fmt.Errorf("redigo: invalid database in URL: %q", s)
+	}
+	return db, nil
 }
 
 // DialTimeout acts like Dial but takes timeouts for establishing the
@@ -98,6 +544,9 @@ func (c *conn) Close() error {
 	return err
 }
 
+// fatal marks the connection as broken and returns the first error that
+// broke the connection. Later calls report the original cause instead of the
+// error from using the closed network connection.
 func (c *conn) fatal(err error) error {
 	c.mu.Lock()
 	if c.err == nil {
@@ -106,10 +555,12 @@ func (c *conn) fatal(err error) error {
 		// other reader or writer.
 		c.conn.Close()
 	}
+	err = c.err
 	c.mu.Unlock()
 	return err
 }
 
+// Err returns the first error that broke the connection.
 func (c *conn) Err() error {
 	c.mu.Lock()
 	err := c.err
@@ -156,6 +607,33 @@ func (c *conn) writeFloat64(n float64) error {
 	return c.writeBytes(strconv.AppendFloat(c.numScratch[:0], n, 'g', -1, 64))
 }
 
+// checkArgs returns an error if an argument cannot be written to the server.
+// The arguments are checked before the command is written so that an invalid
+// argument does not leave a partial command in the write buffer.
+func checkArgs(cmd string, args []interface{}) error {
+	for i, arg := range args {
+		switch arg.(type) {
+		case string, []byte, int, int64, float64, bool, fmt.Stringer, error:
+			continue
+		case nil:
+			return fmt.Errorf("redigo: argument %d of %s is nil", i, cmd)
+		}
+		switch v := reflect.ValueOf(arg); v.Kind() {
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+			continue
+		case reflect.Slice:
+			if v.Type().Elem().Kind() == reflect.Uint8 {
+				continue
+			}
+		}
+		return fmt.Errorf("redigo: argument %d of %s has unsupported type %T", i, cmd, arg)
+	}
+	return nil
+}
+
 func (c *conn) writeCommand(cmd string, args []interface{}) (err error) {
 	c.writeLen('*', 1+len(args))
 	err = c.writeString(cmd)
@@ -180,9 +658,11 @@ func (c *conn) writeCommand(cmd string, args []interface{}) (err error) {
 			} else {
 				err = c.writeString("0")
 			}
-		case nil:
-			err = c.writeString("")
 		default:
+			if v := reflect.ValueOf(arg); v.Kind() == reflect.Slice {
+				err = c.writeBytes(v.Bytes())
+				break
+			}
 			var buf bytes.Buffer
 			fmt.Fprint(&buf, arg)
 			err = c.writeBytes(buf.Bytes())
@@ -217,13 +697,17 @@ func parseLen(p []byte) (int, error) {
 		return -1, nil
 	}
 
+	const maxInt = int(^uint(0) >> 1)
 	var n int
 	for _, b := range p {
-		n *= 10
 		if b < '0' || b > '9' {
 			return -1, errors.New("redigo: illegal bytes in length")
 		}
-		n += int(b - '0')
+		d := int(b - '0')
+		if n > (maxInt-d)/10 {
+			return -1, errors.New("redigo: length out of range")
+		}
+		n = n*10 + d
 	}
 
 	return n, nil
@@ -289,23 +773,41 @@ func (c *conn) readReply() (interface{}, error) {
 	case ':':
 		return parseInt(line[1:])
 	case '$':
-		n, err := parseLen(line[1:])
-		if n < 0 {
+		p, err := c.readBulk(line[1:])
+		if p == nil {
 			return nil, err
 		}
-		p := make([]byte, n)
-		_, err = io.ReadFull(c.br, p)
-		if err != nil {
+		return p, nil
+	case '*':
+		n, err := c.parseReplyLen(line[1:])
+		if n < 0 {
 			return nil, err
 		}
-		if line, err := c.readLine(); err != nil {
-			return nil, err
-		} else if len(line) != 0 {
-			return nil, errors.New("redigo: bad bulk format")
+		r := make([]interface{}, n)
+		for i := range r {
+			r[i], err = c.readReply()
+			if err != nil {
+				return nil, err
+			}
 		}
-		return p, nil
-	case '*':
-		n, err := parseLen(line[1:])
+		return r, nil
+	case '_':
+		if len(line) != 1 {
+			return nil, errors.New("redigo: bad null format")
+		}
+		return nil, nil
+	case ',':
+		return parseDouble(line[1:])
+	case '#':
+		switch {
+		case len(line) == 2 && line[1] == 't':
+			return true, nil
+		case len(line) == 2 && line[1] == 'f':
+			return false, nil
+		}
+		return nil, errors.New("redigo: bad boolean format")
+	case '~', '>':
+		n, err := c.parseReplyLen(line[1:])
 		if n < 0 {
 			return nil, err
 		}
@@ -316,14 +818,126 @@ func (c *conn) readReply() (interface{}, error) {
 				return nil, err
 			}
 		}
+		if line[0] == '>' && c.onPush != nil && c.onPush(r) {
+			// The push was consumed, read the reply that follows.
+			return c.readReply()
+		}
 		return r, nil
+	case '%':
+		n, err := c.parseReplyLen(line[1:])
+		if n < 0 {
+			return nil, err
+		}
+		m := make(Map, n)
+		for i := 0; i < n; i++ {
+			k, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			v, err := c.readReply()
+			if err != nil {
+				return nil, err
+			}
+			var key string
+			switch k := k.(type) {
+			case []byte:
+				key = string(k)
+			case string:
+				key = k
+			case int64:
+				key = strconv.FormatInt(k, 10)
+			default:
+				return nil, fmt.Errorf("redigo: unexpected map key type %T", k)
+			}
+			m[key] = v
+		}
+		return m, nil
+	case '=':
+		p, err := c.readBulk(line[1:])
+		if p == nil {
+			return nil, err
+		}
+		// Strip the three letter format and the colon that follows it.
+		if len(p) < 4 || p[3] != ':' {
+			return nil, errors.New("redigo: bad verbatim string format")
+		}
+		return p[4:], nil
+	case '!':
+		p, err := c.readBulk(line[1:])
+		if p == nil {
+			return nil, err
+		}
+		return Error(p), nil
+	case '(':
+		n, ok := new(big.Int).SetString(string(line[1:]), 10)
+		if !ok {
+			return nil, errors.New("redigo: malformed big number")
+		}
+		return n, nil
+	case '|':
+		// Attributes are metadata about the reply that follows them.
+		// Discard the attributes and return the reply.
+		n, err := c.parseReplyLen(line[1:])
+		if n < 0 {
+			return nil, err
+		}
+		for i := 0; i < 2*n; i++ {
+			if _, err := c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return c.readReply()
 	}
 	return nil, errors.New("redigo: unexpected response line")
 }
 
+// readBulk reads the data of a bulk string with the length p. It returns
+// nil and a nil error for a null bulk string.
+func (c *conn) readBulk(p []byte) ([]byte, error) {
+	n, err := c.parseReplyLen(p)
+	if n < 0 {
+		return nil, err
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		return nil, err
+	}
+	if line, err := c.readLine(); err != nil {
+		return nil, err
+	} else if len(line) != 0 {
+		return nil, errors.New("redigo: bad bulk format")
+	}
+	return data, nil
+}
+
+// parseReplyLen parses the length of a bulk or aggregate reply and checks
+// the length against the limit set with DialMaxReplySize.
+func (c *conn) parseReplyLen(p []byte) (int, error) {
+	n, err := parseLen(p)
+	if c.maxReplySize > 0 && n > c.maxReplySize {
+		return -1, fmt.Errorf("redigo: reply length %d exceeds limit of %d", n, c.maxReplySize)
+	}
+	return n, err
+}
+
+// parseDouble parses a RESP3 double reply, including inf, -inf and nan.
+func parseDouble(p []byte) (interface{}, error) {
+	f, err := strconv.ParseFloat(string(p), 64)
+	if err != nil {
+		return nil, errors.New("redigo: malformed double")
+	}
+	return f, nil
+}
+
 func (c *conn) Send(cmd string, args ...interface{}) error {
+	if err := checkArgs(cmd, args); err != nil {
+		return err
+	}
 	c.mu.Lock()
 	c.pending += 1
+	if c.observer != nil {
+		c.sent = append(c.sent, cmd)
+	}
 	c.mu.Unlock()
 	if c.writeTimeout != 0 {
 		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
@@ -344,7 +958,48 @@ func (c *conn) Flush() error {
 	return nil
 }
 
-func (c *conn) Receive() (reply interface{}, err error) {
+func (c *conn) Receive() (interface{}, error) {
+	return c.receive(c.readTimeout)
+}
+
+func (c *conn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	c.mu.Lock()
+	pending := c.pending
+	c.mu.Unlock()
+	if timeout == 0 {
+		// Clear a deadline left by a previous call with the read timeout.
+		c.conn.SetReadDeadline(time.Time{})
+	}
+	if timeout != 0 && pending == 0 {
+		// No reply is expected, as is the case on a connection in subscribe
+		// mode. If nothing arrives before the timeout, then the connection
+		// can be used again: wait for the first byte and return the timeout
+		// error without breaking the connection.
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+		_, err := c.br.Peek(1)
+		if err != nil {
+			c.conn.SetReadDeadline(time.Time{})
+			if isTimeout(err) {
+				return nil, err
+			}
+			return nil, c.fatal(err)
+		}
+	}
+	reply, err := c.receive(timeout)
+	if timeout != 0 {
+		c.conn.SetReadDeadline(time.Time{})
+	}
+	return reply, err
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func (c *conn) receive(readTimeout time.Duration) (reply interface{}, err error) {
+	var start time.Time
+	var name string
 	c.mu.Lock()
 	// There can be more receives than sends when using pub/sub. To allow
 	// normal use of the connection after unsubscribe from all channels, do not
@@ -352,9 +1007,19 @@ func (c *conn) Receive() (reply interface{}, err error) {
 	if c.pending > 0 {
 		c.pending -= 1
 	}
+	if c.observer != nil {
+		start = time.Now()
+		if len(c.sent) > 0 {
+			name = c.sent[0]
+			c.sent = c.sent[1:]
+		}
+	}
 	c.mu.Unlock()
-	if c.readTimeout != 0 {
-		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	if c.observer != nil {
+		defer func() { c.observer.OnCommand(name, time.Since(start), err) }()
+	}
+	if readTimeout != 0 {
+		c.conn.SetReadDeadline(time.Now().Add(readTimeout))
 	}
 	if reply, err = c.readReply(); err != nil {
 		return nil, c.fatal(err)
@@ -365,9 +1030,120 @@ func (c *conn) Receive() (reply interface{}, err error) {
 	return
 }
 
+// observe notifies the observer of the reply to the i'th of the commands
+// read by do.
+func (c *conn) observe(names []string, i int, start time.Time, reply interface{}, err error) {
+	if err == nil {
+		if e, ok := reply.(Error); ok {
+			err = e
+		}
+	}
+	var name string
+	if i < len(names) {
+		name = names[i]
+	}
+	c.observer.OnCommand(name, time.Since(start), err)
+}
+
 func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
-	if c.writeTimeout != 0 {
-		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return c.do(c.readTimeout, time.Time{}, cmd, args)
+}
+
+func (c *conn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if timeout == 0 {
+		// Clear a deadline left by a previous call with the read timeout.
+		c.conn.SetReadDeadline(time.Time{})
+	}
+	reply, err := c.do(timeout, time.Time{}, cmd, args)
+	if timeout != 0 {
+		c.conn.SetReadDeadline(time.Time{})
+	}
+	return reply, err
+}
+
+// aLongTimeAgo is a deadline in the past used to abort blocked I/O.
+var aLongTimeAgo = time.Unix(1, 0)
+
+func (c *conn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if c.trace == nil {
+		return c.doContext(ctx, cmd, args)
+	}
+	ctx = c.trace.BeforeCommand(ctx, cmd, args)
+	start := time.Now()
+	reply, err := c.doContext(ctx, cmd, args)
+	c.trace.AfterCommand(ctx, cmd, err, time.Since(start))
+	return reply, err
+}
+
+func (c *conn) doContext(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+
+	// Abort I/O when the context is cancelled.
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.conn.SetDeadline(aLongTimeAgo)
+		case <-done:
+		}
+		close(stopped)
+	}()
+
+	reply, err := c.do(c.readTimeout, deadline, cmd, args)
+	close(done)
+	<-stopped
+	c.conn.SetDeadline(time.Time{})
+
+	if err != nil {
+		ctxErr := ctx.Err()
+		if ctxErr == nil && !deadline.IsZero() && !time.Now().Before(deadline) {
+			// The I/O deadline expired before the context's timer fired.
+			ctxErr = context.DeadlineExceeded
+		}
+		if ctxErr != nil {
+			// The reply may be partially read. Mark the connection as
+			// broken so that it is not used again. The I/O error is
+			// caused by aborting the I/O, so report the context error as
+			// the cause.
+			c.fatal(ctxErr)
+			c.mu.Lock()
+			if c.err == err {
+				c.err = ctxErr
+			}
+			c.mu.Unlock()
+			return nil, ctxErr
+		}
+	}
+	return reply, err
+}
+
+// ioDeadline returns the earlier of the deadline and the time timeout from
+// now. A zero timeout or deadline is ignored.
+func ioDeadline(timeout time.Duration, deadline time.Time) time.Time {
+	if timeout != 0 {
+		if t := time.Now().Add(timeout); deadline.IsZero() || t.Before(deadline) {
+			return t
+		}
+	}
+	return deadline
+}
+
+func (c *conn) do(readTimeout time.Duration, deadline time.Time, cmd string, args []interface{}) (interface{}, error) {
+	if err := checkArgs(cmd, args); err != nil {
+		return nil, err
+	}
+
+	var start time.Time
+	if c.observer != nil {
+		start = time.Now()
+	}
+
+	if d := ioDeadline(c.writeTimeout, deadline); !d.IsZero() {
+		c.conn.SetWriteDeadline(d)
 	}
 
 	if cmd != "" {
@@ -381,20 +1157,28 @@ func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 	c.mu.Lock()
 	pending := c.pending
 	c.pending = 0
+	names := c.sent
+	c.sent = nil
 	c.mu.Unlock()
+	if c.observer != nil && cmd != "" {
+		names = append(names, cmd)
+	}
 
-	if c.readTimeout != 0 {
-		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	if d := ioDeadline(readTimeout, deadline); !d.IsZero() {
+		c.conn.SetReadDeadline(d)
 	}
 
 	if cmd == "" {
 		reply := make([]interface{}, pending)
 		for i := range reply {
-			if r, e := c.readReply(); e != nil {
+			r, e := c.readReply()
+			if c.observer != nil {
+				c.observe(names, i, start, r, e)
+			}
+			if e != nil {
 				return nil, c.fatal(e)
-			} else {
-				reply[i] = r
 			}
+			reply[i] = r
 		}
 		return reply, nil
 	}
@@ -403,7 +1187,11 @@ func (c *conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 	var reply interface{}
 	for i := 0; i <= pending; i++ {
 		var e error
-		if reply, e = c.readReply(); e != nil {
+		reply, e = c.readReply()
+		if c.observer != nil {
+			c.observe(names, i, start, reply, e)
+		}
+		if e != nil {
 			return nil, c.fatal(e)
 		}
 		if e, ok := reply.(Error); ok && err == nil {
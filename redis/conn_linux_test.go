@@ -0,0 +1,85 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sockopts returns the keep-alive, keep-alive idle time in seconds and
+// no-delay socket options of a connection returned by Dial.
+func sockopts(t *testing.T, c Conn) (keepAlive, idle, noDelay int) {
+	rc, err := c.(*conn).conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("SyscallConn returned %v", err)
+	}
+	var errs [3]error
+	rc.Control(func(fd uintptr) {
+		keepAlive, errs[0] = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+		idle, errs[1] = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+		noDelay, errs[2] = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("GetsockoptInt returned %v", err)
+		}
+	}
+	return keepAlive, idle, noDelay
+}
+
+func TestDialTCPOptions(t *testing.T) {
+	s := NewFakeServer(t, func(args []string) string { return "+OK\r\n" })
+	defer s.Close()
+
+	tests := []struct {
+		options                  []DialOption
+		keepAlive, idle, noDelay int
+	}{
+		{nil, 1, 300, 1},
+		{[]DialOption{DialKeepAlive(time.Minute), DialTCPNoDelay(false)}, 1, 60, 0},
+		// The idle time is not checked when keep-alives are disabled.
+		{[]DialOption{DialKeepAlive(0)}, 0, -1, 1},
+	}
+	for i, tt := range tests {
+		c, err := Dial("tcp", s.Addr(), tt.options...)
+		if err != nil {
+			t.Fatalf("%d: Dial returned %v", i, err)
+		}
+		keepAlive, idle, noDelay := sockopts(t, c)
+		c.Close()
+		if keepAlive != tt.keepAlive || (tt.idle >= 0 && idle != tt.idle) || noDelay != tt.noDelay {
+			t.Errorf("%d: keepalive=%d idle=%d nodelay=%d, want %d %d %d",
+				i, keepAlive, idle, noDelay, tt.keepAlive, tt.idle, tt.noDelay)
+		}
+	}
+}
+
+func TestDialUnixIgnoresTCPOptions(t *testing.T) {
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "redis.sock"))
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	defer l.Close()
+
+	c, err := Dial("unix", l.Addr().String(), DialKeepAlive(time.Minute), DialTCPNoDelay(false))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	c.Close()
+}
@@ -32,10 +32,11 @@
 //  Do(commandName string, args ...interface{}) (reply interface{}, err error)
 //
 // Arguments of type string and []byte are sent to the server as is. The value
-// false is converted to "0" and the value true is converted to "1". The value
-// nil is converted to "". All other values are converted to a string using the
-// fmt.Fprint function. Command replies are represented using the following Go
-// types:
+// false is converted to "0" and the value true is converted to "1". Numbers
+// and values implementing fmt.Stringer are converted to a string using the
+// fmt.Fprint function. Other values, including nil, are rejected with an error
+// before the command is written to the connection. Command replies are
+// represented using the following Go types:
 //
 //  Redis type          Go type
 //  error               redis.Error
@@ -44,6 +45,21 @@
 //  bulk                []byte or nil if value not present.
 //  multi-bulk          []interface{} or nil if value not present.
 //
+// Connections dialed with DialProtocol(3) use the RESP3 protocol. RESP3
+// adds the following types:
+//
+//  Redis type          Go type
+//  map                 redis.Map
+//  set, push           []interface{}
+//  double              float64
+//  boolean             bool
+//  null                nil
+//  verbatim string     []byte without the format prefix
+//  blob error          redis.Error
+//  big number          *big.Int
+//
+// Attributes sent before a reply are discarded.
+//
 // The Redis command reference (http://redis.io/commands) documents the Redis
 // type returned for each command. Use type assertions to convert from
 // interface{} to the specific Go type for the command result.
@@ -110,7 +126,7 @@
 // send and flush a subscription management command. The receive method
 // converts a pushed message to convenient types for use in a type switch.
 //
-//  psc := PubSubConn{c}
+//  psc := NewPubSubConn(c)
 //  psc.Subscribe("example")
 //  for {
 //      switch v := psc.Receive().(type) {
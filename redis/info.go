@@ -0,0 +1,73 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"strings"
+)
+
+// Info returns the output of the INFO command as a map from section name to
+// the fields of the section. Section names are converted to lower case, so
+// that they match the names passed to the command, for example "server" or
+// "replication". If sections are given, then only those sections are
+// requested from the server.
+//
+//	info, err := redis.Info(c, "replication")
+//	if err != nil {
+//	    // handle error
+//	}
+//	role := info["replication"]["role"]
+func Info(c Conn, sections ...string) (map[string]map[string]string, error) {
+	args := make([]interface{}, len(sections))
+	for i, section := range sections {
+		args[i] = section
+	}
+	s, err := String(c.Do("INFO", args...))
+	if err != nil {
+		return nil, err
+	}
+	return parseInfo(s), nil
+}
+
+func parseInfo(s string) map[string]map[string]string {
+	info := make(map[string]map[string]string)
+	var fields map[string]string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			name := strings.ToLower(strings.TrimSpace(line[1:]))
+			fields = info[name]
+			if fields == nil {
+				fields = make(map[string]string)
+				info[name] = fields
+			}
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		if fields == nil {
+			// Fields before the first section header.
+			fields = make(map[string]string)
+			info[""] = fields
+		}
+		fields[line[:i]] = line[i+1:]
+	}
+	return info
+}
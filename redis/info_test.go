@@ -0,0 +1,69 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const infoReply = "# Server\r\nredis_version:7.2.4\r\nexecutable:/usr/bin/redis-server\r\n\r\n" +
+	"# Replication\r\nrole:master\r\nslave0:ip=10.0.0.2,port=6379,state=online\r\n\r\n"
+
+func TestInfo(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(infoReply), infoReply)
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	info, err := redis.Info(c, "server", "replication")
+	if err != nil {
+		t.Fatalf("Info returned %v", err)
+	}
+	want := map[string]map[string]string{
+		"server": {
+			"redis_version": "7.2.4",
+			"executable":    "/usr/bin/redis-server",
+		},
+		"replication": {
+			"role":   "master",
+			"slave0": "ip=10.0.0.2,port=6379,state=online",
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Info returned %v, want %v", info, want)
+	}
+	mu.Lock()
+	if cmd != "INFO server replication" {
+		t.Errorf("Info sent %q, want %q", cmd, "INFO server replication")
+	}
+	mu.Unlock()
+}
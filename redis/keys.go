@@ -0,0 +1,194 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ExistsEach reports whether each of the keys exists. The EXISTS command
+// for each key is pipelined in a single round trip. The returned map has an
+// entry for every key. If the server returns an error for a key, then
+// ExistsEach returns the first such error after reading all replies.
+func ExistsEach(c Conn, keys ...string) (map[string]bool, error) {
+	for _, key := range keys {
+		if err := c.Send("EXISTS", key); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(keys))
+	var firstErr error
+	for _, key := range keys {
+		ok, err := Bool(c.Receive())
+		if err == nil {
+			exists[key] = ok
+			continue
+		}
+		if _, ok := err.(Error); !ok {
+			return nil, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return exists, nil
+}
+
+// Expire sets the time to live of key to d and reports whether the key
+// exists. Whole seconds are set with the EXPIRE command, other durations are
+// set in milliseconds with the PEXPIRE command. A duration that is not
+// positive deletes the key.
+func Expire(c Conn, key string, d time.Duration) (bool, error) {
+	if d%time.Second == 0 {
+		return Bool(c.Do("EXPIRE", key, int64(d/time.Second)))
+	}
+	ms := int64(d / time.Millisecond)
+	if ms == 0 && d > 0 {
+		// Round up durations shorter than the resolution of PEXPIRE.
+		ms = 1
+	}
+	return Bool(c.Do("PEXPIRE", key, ms))
+}
+
+// ExpireAt sets key to expire at t and reports whether the key exists. Times
+// with whole seconds are set with the EXPIREAT command, other times are set
+// in milliseconds with the PEXPIREAT command. A time in the past deletes the
+// key.
+func ExpireAt(c Conn, key string, t time.Time) (bool, error) {
+	if t.Nanosecond() == 0 {
+		return Bool(c.Do("EXPIREAT", key, t.Unix()))
+	}
+	ms := t.UnixNano() / int64(time.Millisecond)
+	if t.Nanosecond()%int(time.Millisecond) != 0 {
+		// Round up to the next millisecond so the key does not expire
+		// before t.
+		ms++
+	}
+	return Bool(c.Do("PEXPIREAT", key, ms))
+}
+
+// TTL returns the remaining time to live of key using the TTL command. The
+// boolean result reports whether the key has an expiration. If the key does
+// not exist, then TTL returns ErrNoSuchKey.
+func TTL(c Conn, key string) (time.Duration, bool, error) {
+	return ttlReply(c, "TTL", key, time.Second)
+}
+
+// PTTL is like TTL, but uses the PTTL command to get the time to live with
+// millisecond precision.
+func PTTL(c Conn, key string) (time.Duration, bool, error) {
+	return ttlReply(c, "PTTL", key, time.Millisecond)
+}
+
+func ttlReply(c Conn, cmd string, key string, unit time.Duration) (time.Duration, bool, error) {
+	n, err := Int64(c.Do(cmd, key))
+	switch {
+	case err != nil:
+		return 0, false, err
+	case n == -2:
+		return 0, false, ErrNoSuchKey
+	case n == -1:
+		// The key exists and has no expiration.
+		return 0, false, nil
+	}
+	return time.Duration(n) * unit, true, nil
+}
+
+// DeleteByPattern deletes the keys matching pattern and returns the number of
+// keys deleted. The keys are found with the SCAN command, not KEYS, and are
+// deleted in batches of batchSize keys with DEL, or with UNLINK if useUnlink
+// is true to reclaim memory in the background on the server. If batchSize is
+// not positive, then batches of 100 keys are used. If an error occurs, then
+// DeleteByPattern stops and returns the number of keys deleted so far with
+// the error.
+//
+// As documented for SCAN, keys added while DeleteByPattern runs may not be
+// deleted.
+func DeleteByPattern(c Conn, pattern string, batchSize int, useUnlink bool) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	cmd := "DEL"
+	if useUnlink {
+		cmd = "UNLINK"
+	}
+	var deleted int64
+	batch := make([]interface{}, 0, batchSize)
+	flush := func() error {
+		n, err := Int64(c.Do(cmd, batch...))
+		deleted += n
+		batch = batch[:0]
+		return err
+	}
+	it := NewScanIterator(c, pattern, batchSize)
+	for it.Next() {
+		batch = append(batch, it.Val())
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return deleted, err
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// CopyOptions specifies the options of the COPY command.
+type CopyOptions struct {
+	// Database of the destination key. If zero, then the key is copied in
+	// the current database.
+	DestinationDB int
+
+	// Replace the destination key if it exists.
+	Replace bool
+}
+
+// Copy copies the value at src to dst using the COPY command and reports
+// whether the value was copied. The value is not copied if the destination
+// key exists and Replace is not set. COPY requires Redis 6.2 or later. If the
+// server does not know the command, then Copy returns an error that names
+// the required version.
+func Copy(c Conn, src, dst string, opts CopyOptions) (bool, error) {
+	if opts.DestinationDB < 0 {
+		return false, errors.New("redigo: Copy requires a destination database that is not negative")
+	}
+	args := []interface{}{src, dst}
+	if opts.DestinationDB != 0 {
+		args = append(args, "DB", opts.DestinationDB)
+	}
+	if opts.Replace {
+		args = append(args, "REPLACE")
+	}
+	copied, err := Bool(c.Do("COPY", args...))
+	if isUnknownCommand(err) {
+		return false, fmt.Errorf("redigo: COPY requires Redis 6.2 or later: %v", err)
+	}
+	return copied, err
+}
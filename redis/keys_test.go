@@ -0,0 +1,320 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestExistsEach(t *testing.T) {
+	present := map[string]bool{"a": true, "c": true}
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] != "EXISTS" || len(args) != 2:
+			return "-ERR unexpected command\r\n"
+		case args[1] == "bad":
+			return "-WRONGTYPE bad key\r\n"
+		case present[args[1]]:
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	exists, err := redis.ExistsEach(c, "a", "b", "c", "d")
+	if err != nil {
+		t.Fatalf("ExistsEach returned error %v", err)
+	}
+	want := map[string]bool{"a": true, "b": false, "c": true, "d": false}
+	if !reflect.DeepEqual(exists, want) {
+		t.Errorf("ExistsEach = %v, want %v", exists, want)
+	}
+
+	if _, err := redis.ExistsEach(c, "bad", "a"); err == nil || err.Error() != "WRONGTYPE bad key" {
+		t.Errorf("ExistsEach with bad key returned error %v, want WRONGTYPE bad key", err)
+	}
+
+	// All replies are read after an error, so the connection is still
+	// usable.
+	exists, err = redis.ExistsEach(c, "c")
+	if err != nil || !exists["c"] {
+		t.Errorf("ExistsEach after error = %v, %v, want map[c:true], nil", exists, err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		if args[1] == "missing" {
+			return ":0\r\n"
+		}
+		return ":1\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	at := time.Unix(1700000000, 0)
+	tests := []struct {
+		f   func() (bool, error)
+		cmd string
+		ok  bool
+	}{
+		{func() (bool, error) { return redis.Expire(c, "k", time.Minute) }, "EXPIRE k 60", true},
+		{func() (bool, error) { return redis.Expire(c, "k", 1500*time.Millisecond) }, "PEXPIRE k 1500", true},
+		{func() (bool, error) { return redis.Expire(c, "k", time.Microsecond) }, "PEXPIRE k 1", true},
+		{func() (bool, error) { return redis.Expire(c, "missing", time.Second) }, "EXPIRE missing 1", false},
+		{func() (bool, error) { return redis.ExpireAt(c, "k", at) }, "EXPIREAT k 1700000000", true},
+		{func() (bool, error) { return redis.ExpireAt(c, "k", at.Add(250*time.Millisecond)) }, "PEXPIREAT k 1700000000250", true},
+		{func() (bool, error) { return redis.ExpireAt(c, "k", at.Add(time.Microsecond)) }, "PEXPIREAT k 1700000000001", true},
+		{func() (bool, error) { return redis.ExpireAt(c, "missing", at) }, "EXPIREAT missing 1700000000", false},
+	}
+	for _, tt := range tests {
+		ok, err := tt.f()
+		mu.Lock()
+		sent := cmd
+		mu.Unlock()
+		if err != nil {
+			t.Errorf("%s returned error %v", tt.cmd, err)
+			continue
+		}
+		if sent != tt.cmd {
+			t.Errorf("sent %q, want %q", sent, tt.cmd)
+		}
+		if ok != tt.ok {
+			t.Errorf("%s = %v, want %v", tt.cmd, ok, tt.ok)
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch args[1] {
+		case "missing":
+			return ":-2\r\n"
+		case "persistent":
+			return ":-1\r\n"
+		}
+		if args[0] == "PTTL" {
+			return ":1500\r\n"
+		}
+		return ":90\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		name string
+		fn   func(redis.Conn, string) (time.Duration, bool, error)
+		key  string
+		d    time.Duration
+		ok   bool
+		err  error
+	}{
+		{"TTL", redis.TTL, "k", 90 * time.Second, true, nil},
+		{"PTTL", redis.PTTL, "k", 1500 * time.Millisecond, true, nil},
+		{"TTL", redis.TTL, "persistent", 0, false, nil},
+		{"PTTL", redis.PTTL, "persistent", 0, false, nil},
+		{"TTL", redis.TTL, "missing", 0, false, redis.ErrNoSuchKey},
+		{"PTTL", redis.PTTL, "missing", 0, false, redis.ErrNoSuchKey},
+	}
+	for _, tt := range tests {
+		d, ok, err := tt.fn(c, tt.key)
+		if d != tt.d || ok != tt.ok || err != tt.err {
+			t.Errorf("%s(%s) returned %v, %v, %v, want %v, %v, %v", tt.name, tt.key, d, ok, err, tt.d, tt.ok, tt.err)
+		}
+	}
+}
+
+func TestDeleteByPattern(t *testing.T) {
+	var mu sync.Mutex
+	var cmds []string
+	keys := map[string]bool{}
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		cmds = append(cmds, strings.Join(args, " "))
+		switch args[0] {
+		case "SCAN":
+			// Two pages of matching keys, the second page ends the
+			// iteration.
+			switch args[1] {
+			case "0":
+				return "*2\r\n$1\r\n5\r\n*3\r\n$3\r\nk:1\r\n$3\r\nk:2\r\n$3\r\nk:3\r\n"
+			case "5":
+				return "*2\r\n$1\r\n0\r\n*2\r\n$3\r\nk:4\r\n$3\r\nk:1\r\n"
+			}
+			return "-ERR invalid cursor\r\n"
+		case "DEL", "UNLINK":
+			n := 0
+			for _, k := range args[1:] {
+				if keys[k] {
+					delete(keys, k)
+					n++
+				}
+			}
+			return fmt.Sprintf(":%d\r\n", n)
+		}
+		return "-ERR unexpected command\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	reset := func() {
+		mu.Lock()
+		cmds = nil
+		for _, k := range []string{"k:1", "k:2", "k:3", "k:4"} {
+			keys[k] = true
+		}
+		mu.Unlock()
+	}
+
+	reset()
+	n, err := redis.DeleteByPattern(c, "k:*", 2, false)
+	if err != nil || n != 4 {
+		t.Errorf("DeleteByPattern = %d, %v, want 4, nil", n, err)
+	}
+	want := []string{"SCAN 0 MATCH k:* COUNT 2", "DEL k:1 k:2", "SCAN 5 MATCH k:* COUNT 2", "DEL k:3 k:4", "DEL k:1"}
+	mu.Lock()
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("DeleteByPattern sent %q, want %q", cmds, want)
+	}
+	mu.Unlock()
+
+	reset()
+	if n, err := redis.DeleteByPattern(c, "k:*", 10, true); err != nil || n != 4 {
+		t.Errorf("DeleteByPattern with UNLINK = %d, %v, want 4, nil", n, err)
+	}
+	want = []string{"SCAN 0 MATCH k:* COUNT 10", "SCAN 5 MATCH k:* COUNT 10", "UNLINK k:1 k:2 k:3 k:4 k:1"}
+	mu.Lock()
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("DeleteByPattern sent %q, want %q", cmds, want)
+	}
+	mu.Unlock()
+}
+
+func TestDeleteByPatternScanError(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch {
+		case args[0] == "SCAN" && args[1] == "0":
+			return "*2\r\n$1\r\n5\r\n*1\r\n$3\r\nk:1\r\n"
+		case args[0] == "SCAN":
+			return "-ERR invalid cursor\r\n"
+		case args[0] == "DEL":
+			return ":1\r\n"
+		}
+		return "-ERR unexpected command\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	n, err := redis.DeleteByPattern(c, "k:*", 1, false)
+	if err == nil || err.Error() != "ERR invalid cursor" || n != 1 {
+		t.Errorf("DeleteByPattern = %d, %v, want 1, ERR invalid cursor", n, err)
+	}
+	if _, err := c.Do("DEL", "x"); err != nil {
+		t.Errorf("connection unusable after scan error: %v", err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		switch {
+		case args[1] == "old":
+			return "-ERR unknown command 'COPY', with args beginning with: 'old' 'dst'\r\n"
+		case args[2] == "exists" && args[len(args)-1] != "REPLACE":
+			return ":0\r\n"
+		}
+		return ":1\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		dst    string
+		opts   redis.CopyOptions
+		cmd    string
+		copied bool
+	}{
+		{"dst", redis.CopyOptions{}, "COPY src dst", true},
+		{"dst", redis.CopyOptions{DestinationDB: 2}, "COPY src dst DB 2", true},
+		{"exists", redis.CopyOptions{}, "COPY src exists", false},
+		{"exists", redis.CopyOptions{DestinationDB: 1, Replace: true}, "COPY src exists DB 1 REPLACE", true},
+	}
+	for _, tt := range tests {
+		copied, err := redis.Copy(c, "src", tt.dst, tt.opts)
+		if err != nil || copied != tt.copied {
+			t.Errorf("Copy(%s, %+v) = %v, %v, want %v, nil", tt.dst, tt.opts, copied, err, tt.copied)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("Copy(%s, %+v) sent %q, want %q", tt.dst, tt.opts, cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+
+	if _, err := redis.Copy(c, "old", "dst", redis.CopyOptions{}); err == nil || !strings.Contains(err.Error(), "requires Redis 6.2") {
+		t.Errorf("Copy on old server returned error %v, want version error", err)
+	}
+	if _, err := redis.Copy(c, "src", "dst", redis.CopyOptions{DestinationDB: -1}); err == nil {
+		t.Error("Copy with negative database returned nil error")
+	}
+}
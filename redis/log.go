@@ -16,22 +16,42 @@ package redis
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log"
+	"math/rand"
+	"time"
 )
 
-// NewLoggingConn returns a logging wrapper around a connection.
+// NewLoggingConn returns a logging wrapper around a connection. Each call to
+// Do, Send, Flush and Receive is logged with the command arguments, the
+// reply, the error and the duration of the call. Strings and byte slices are
+// quoted and values longer than 32 bytes or elements are truncated.
 func NewLoggingConn(conn Conn, logger *log.Logger, prefix string) Conn {
+	return NewSampledLoggingConn(conn, logger, prefix, 1)
+}
+
+// NewSampledLoggingConn returns a logging wrapper around a connection that
+// logs a random sample of the calls. The rate is the fraction of calls that
+// are logged, from 0 for none to 1 for all. Calls that are not sampled are
+// passed to the connection without formatting a log message, so the wrapper
+// can be left enabled in production.
+func NewSampledLoggingConn(conn Conn, logger *log.Logger, prefix string, rate float64) Conn {
 	if prefix != "" {
 		prefix = prefix + "."
 	}
-	return &loggingConn{conn, logger, prefix}
+	return &loggingConn{conn, logger, prefix, rate}
 }
 
 type loggingConn struct {
 	Conn
 	logger *log.Logger
 	prefix string
+	rate   float64
+}
+
+func (c *loggingConn) sampled() bool {
+	return c.rate >= 1 || (c.rate > 0 && rand.Float64() < c.rate)
 }
 
 func (c *loggingConn) Close() error {
@@ -79,10 +99,10 @@ func (c *loggingConn) printValue(buf *bytes.Buffer, v interface{}) {
 	}
 }
 
-func (c *loggingConn) print(method, commandName string, args []interface{}, reply interface{}, err error) {
+func (c *loggingConn) print(method, commandName string, args []interface{}, reply interface{}, err error, start time.Time) {
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "%s%s(", c.prefix, method)
-	if method != "Receive" {
+	if method != "Receive" && method != "Flush" {
 		buf.WriteString(commandName)
 		for _, arg := range args {
 			buf.WriteString(", ")
@@ -90,28 +110,80 @@ func (c *loggingConn) print(method, commandName string, args []interface{}, repl
 		}
 	}
 	buf.WriteString(") -> (")
-	if method != "Send" {
+	if method != "Send" && method != "Flush" {
 		c.printValue(&buf, reply)
 		buf.WriteString(", ")
 	}
-	fmt.Fprintf(&buf, "%v)", err)
+	fmt.Fprintf(&buf, "%v) in %v", err, time.Since(start))
 	c.logger.Output(3, buf.String())
 }
 
 func (c *loggingConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if !c.sampled() {
+		return c.Conn.Do(commandName, args...)
+	}
+	start := time.Now()
 	reply, err := c.Conn.Do(commandName, args...)
-	c.print("Do", commandName, args, reply, err)
+	c.print("Do", commandName, args, reply, err, start)
+	return reply, err
+}
+
+func (c *loggingConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	if !c.sampled() {
+		return DoContext(c.Conn, ctx, commandName, args...)
+	}
+	start := time.Now()
+	reply, err := DoContext(c.Conn, ctx, commandName, args...)
+	c.print("DoContext", commandName, args, reply, err, start)
+	return reply, err
+}
+
+func (c *loggingConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	if !c.sampled() {
+		return DoWithTimeout(c.Conn, timeout, commandName, args...)
+	}
+	start := time.Now()
+	reply, err := DoWithTimeout(c.Conn, timeout, commandName, args...)
+	c.print("DoWithTimeout", commandName, args, reply, err, start)
 	return reply, err
 }
 
 func (c *loggingConn) Send(commandName string, args ...interface{}) error {
+	if !c.sampled() {
+		return c.Conn.Send(commandName, args...)
+	}
+	start := time.Now()
 	err := c.Conn.Send(commandName, args...)
-	c.print("Send", commandName, args, nil, err)
+	c.print("Send", commandName, args, nil, err, start)
+	return err
+}
+
+func (c *loggingConn) Flush() error {
+	if !c.sampled() {
+		return c.Conn.Flush()
+	}
+	start := time.Now()
+	err := c.Conn.Flush()
+	c.print("Flush", "", nil, nil, err, start)
 	return err
 }
 
 func (c *loggingConn) Receive() (interface{}, error) {
+	if !c.sampled() {
+		return c.Conn.Receive()
+	}
+	start := time.Now()
 	reply, err := c.Conn.Receive()
-	c.print("Receive", "", nil, reply, err)
+	c.print("Receive", "", nil, reply, err, start)
+	return reply, err
+}
+
+func (c *loggingConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	if !c.sampled() {
+		return ReceiveWithTimeout(c.Conn, timeout)
+	}
+	start := time.Now()
+	reply, err := ReceiveWithTimeout(c.Conn, timeout)
+	c.print("Receive", "", nil, reply, err, start)
 	return reply, err
 }
@@ -0,0 +1,67 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redistest"
+)
+
+func TestLoggingConn(t *testing.T) {
+	tc := redistest.NewConn()
+	tc.AddReply("OK", nil)
+	tc.AddReply([]byte("\x00\xff"), nil)
+	tc.SetError("DEL", redis.Error("ERR broken"))
+
+	var buf bytes.Buffer
+	c := redis.NewLoggingConn(tc, log.New(&buf, "", 0), "test")
+	c.Do("SET", "k", strings.Repeat("x", 40))
+	c.Send("GET", []byte("k"))
+	c.Flush()
+	c.Receive()
+	c.Do("DEL", "k")
+
+	// Remove the durations, they vary from run to run.
+	got := regexp.MustCompile(` in \S+\n`).ReplaceAllString(buf.String(), "\n")
+	want := `test.Do(SET, "k", "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"...) -> ("OK", <nil>)
+test.Send(GET, "k") -> (<nil>)
+test.Flush() -> (<nil>)
+test.Receive() -> ("\x00\xff", <nil>)
+test.Do(DEL, "k") -> (ERR broken, ERR broken)
+`
+	if got != want {
+		t.Errorf("log =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSampledLoggingConn(t *testing.T) {
+	tc := redistest.NewConn()
+	tc.AddReply("OK", nil)
+
+	var buf bytes.Buffer
+	c := redis.NewSampledLoggingConn(tc, log.New(&buf, "", 0), "", 0)
+	if _, err := c.Do("SET", "k", "v"); err != nil {
+		t.Fatalf("Do returned %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("logged %q with a sampling rate of zero", buf.String())
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+)
+
+// Monitor issues the MONITOR command on the connection and returns a channel
+// that receives each command processed by the server, formatted as by the
+// server. The channel is closed when a reply cannot be read, for example
+// because the connection is closed. Use the connection Err method to find
+// out why the channel was closed. The application must receive from the
+// channel until it is closed.
+//
+// After a call to Monitor, the connection cannot be used for other commands.
+// The application must close the connection to stop monitoring. Dial a
+// dedicated connection for Monitor instead of getting one from a Pool,
+// because closing a pooled connection returns it to the pool.
+func Monitor(c Conn) (<-chan string, error) {
+	reply, err := String(c.Do("MONITOR"))
+	if err != nil {
+		return nil, err
+	}
+	if reply != "OK" {
+		return nil, fmt.Errorf("redigo: unexpected reply to MONITOR: %s", reply)
+	}
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for {
+			line, err := String(c.Receive())
+			if err != nil {
+				return
+			}
+			ch <- line
+		}
+	}()
+	return ch, nil
+}
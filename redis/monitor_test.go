@@ -0,0 +1,64 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestMonitor(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		if args[0] == "MONITOR" {
+			return "+OK\r\n" +
+				"+1339518083.107412 [0 127.0.0.1:60866] \"keys\" \"*\"\r\n" +
+				"+1339518087.877697 [0 127.0.0.1:60866] \"dbsize\"\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	ch, err := redis.Monitor(c)
+	if err != nil {
+		t.Fatalf("Monitor returned %v", err)
+	}
+	var lines []string
+	for i := 0; i < 2; i++ {
+		lines = append(lines, <-ch)
+	}
+	want := []string{
+		`1339518083.107412 [0 127.0.0.1:60866] "keys" "*"`,
+		`1339518087.877697 [0 127.0.0.1:60866] "dbsize"`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	s.DropConns()
+	if line, ok := <-ch; ok {
+		t.Errorf("received %q after the connection was closed, want closed channel", line)
+	}
+	if c.Err() == nil {
+		t.Error("c.Err() = nil after the channel was closed")
+	}
+}
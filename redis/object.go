@@ -0,0 +1,88 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// objectReply returns the reply to the OBJECT subcommand for key. Depending
+// on the server version, the reply for a missing key is nil or an error.
+func objectReply(c Conn, subcommand string, key string) (interface{}, error) {
+	reply, err := c.Do("OBJECT", subcommand, key)
+	if e, ok := err.(Error); ok && e == "ERR no such key" {
+		return nil, ErrNoSuchKey
+	}
+	if err == nil && reply == nil {
+		return nil, ErrNoSuchKey
+	}
+	return reply, err
+}
+
+// ObjectEncoding returns the internal encoding of the value at key, such as
+// "listpack" or "hashtable", using the OBJECT ENCODING command. If the key
+// does not exist, then ObjectEncoding returns ErrNoSuchKey.
+func ObjectEncoding(c Conn, key string) (string, error) {
+	return String(objectReply(c, "ENCODING", key))
+}
+
+// ObjectIdletime returns the time since the value at key was last accessed
+// using the OBJECT IDLETIME command. The server reports the time in seconds.
+// If the key does not exist, then ObjectIdletime returns ErrNoSuchKey.
+func ObjectIdletime(c Conn, key string) (time.Duration, error) {
+	n, err := Int64(objectReply(c, "IDLETIME", key))
+	return time.Duration(n) * time.Second, err
+}
+
+// ObjectRefcount returns the number of references to the value at key using
+// the OBJECT REFCOUNT command. If the key does not exist, then ObjectRefcount
+// returns ErrNoSuchKey.
+func ObjectRefcount(c Conn, key string) (int64, error) {
+	return Int64(objectReply(c, "REFCOUNT", key))
+}
+
+// ObjectFreq returns the logarithmic access frequency counter of the value
+// at key using the OBJECT FREQ command. The server tracks the counter only
+// when an LFU maxmemory policy is selected and returns an error otherwise.
+// If the key does not exist, then ObjectFreq returns ErrNoSuchKey.
+func ObjectFreq(c Conn, key string) (int64, error) {
+	return Int64(objectReply(c, "FREQ", key))
+}
+
+// MemoryUsage returns the number of bytes used to store key and its value
+// using the MEMORY USAGE command. If samples is greater than zero, then it is
+// sent as the number of nested values the server samples to estimate the
+// size of an aggregate value. If the key does not exist, then MemoryUsage
+// returns ErrNoSuchKey. MemoryUsage returns an error if the server does not
+// support the MEMORY command.
+func MemoryUsage(c Conn, key string, samples int) (int64, error) {
+	if samples < 0 {
+		return 0, errors.New("redigo: negative MEMORY USAGE samples")
+	}
+	args := []interface{}{"USAGE", key}
+	if samples > 0 {
+		args = append(args, "SAMPLES", samples)
+	}
+	reply, err := c.Do("MEMORY", args...)
+	if isUnknownCommand(err) {
+		return 0, fmt.Errorf("redigo: MEMORY USAGE requires Redis 4.0 or later: %v", err)
+	}
+	if err == nil && reply == nil {
+		return 0, ErrNoSuchKey
+	}
+	return Int64(reply, err)
+}
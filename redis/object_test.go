@@ -0,0 +1,142 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestObjectHelpers(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		if args[0] != "OBJECT" || len(args) != 3 {
+			return "-ERR unknown command\r\n"
+		}
+		switch args[2] {
+		case "old":
+			return "-ERR no such key\r\n"
+		case "new":
+			return "$-1\r\n"
+		}
+		switch args[1] {
+		case "ENCODING":
+			return "$8\r\nlistpack\r\n"
+		case "IDLETIME":
+			return ":90\r\n"
+		case "REFCOUNT":
+			return ":2\r\n"
+		case "FREQ":
+			return ":5\r\n"
+		}
+		return "-ERR unknown subcommand\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if enc, err := redis.ObjectEncoding(c, "k"); enc != "listpack" || err != nil {
+		t.Errorf("ObjectEncoding returned %q, %v, want listpack", enc, err)
+	}
+	if d, err := redis.ObjectIdletime(c, "k"); d != 90*time.Second || err != nil {
+		t.Errorf("ObjectIdletime returned %v, %v, want 90s", d, err)
+	}
+	if n, err := redis.ObjectRefcount(c, "k"); n != 2 || err != nil {
+		t.Errorf("ObjectRefcount returned %d, %v, want 2", n, err)
+	}
+
+	for _, key := range []string{"old", "new"} {
+		if _, err := redis.ObjectEncoding(c, key); err != redis.ErrNoSuchKey {
+			t.Errorf("ObjectEncoding(%s) returned %v, want %v", key, err, redis.ErrNoSuchKey)
+		}
+		if _, err := redis.ObjectIdletime(c, key); err != redis.ErrNoSuchKey {
+			t.Errorf("ObjectIdletime(%s) returned %v, want %v", key, err, redis.ErrNoSuchKey)
+		}
+		if _, err := redis.ObjectRefcount(c, key); err != redis.ErrNoSuchKey {
+			t.Errorf("ObjectRefcount(%s) returned %v, want %v", key, err, redis.ErrNoSuchKey)
+		}
+		if _, err := redis.ObjectFreq(c, key); err != redis.ErrNoSuchKey {
+			t.Errorf("ObjectFreq(%s) returned %v, want %v", key, err, redis.ErrNoSuchKey)
+		}
+	}
+	if n, err := redis.ObjectFreq(c, "k"); n != 5 || err != nil {
+		t.Errorf("ObjectFreq returned %d, %v, want 5", n, err)
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		switch {
+		case args[0] != "MEMORY":
+			return "-ERR unknown command\r\n"
+		case args[2] == "old":
+			return "-ERR unknown command 'MEMORY'\r\n"
+		case args[2] == "missing":
+			return "$-1\r\n"
+		case len(args) == 5:
+			return ":2048\r\n"
+		}
+		return ":1024\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		samples int
+		n       int64
+		cmd     string
+	}{
+		{0, 1024, "MEMORY USAGE k"},
+		{10, 2048, "MEMORY USAGE k SAMPLES 10"},
+	}
+	for _, tt := range tests {
+		n, err := redis.MemoryUsage(c, "k", tt.samples)
+		if n != tt.n || err != nil {
+			t.Errorf("MemoryUsage(%d) returned %d, %v, want %d", tt.samples, n, err, tt.n)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("MemoryUsage(%d) sent %q, want %q", tt.samples, cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+
+	if _, err := redis.MemoryUsage(c, "missing", 0); err != redis.ErrNoSuchKey {
+		t.Errorf("MemoryUsage(missing) returned %v, want %v", err, redis.ErrNoSuchKey)
+	}
+	if _, err := redis.MemoryUsage(c, "old", 0); err == nil || !strings.Contains(err.Error(), "requires Redis 4.0") {
+		t.Errorf("MemoryUsage on old server returned %v, want version error", err)
+	}
+	if _, err := redis.MemoryUsage(c, "k", -1); err == nil {
+		t.Error("MemoryUsage(-1) returned nil error")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ping sends the PING command, checks that the server replied with PONG and
+// returns the round trip time of the command.
+func Ping(c Conn) (time.Duration, error) {
+	start := time.Now()
+	reply, err := c.Do("PING")
+	d := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	if s, ok := reply.(string); !ok || s != "PONG" {
+		return 0, fmt.Errorf("redigo: unexpected reply to PING: %v", reply)
+	}
+	return d, nil
+}
+
+// PingMessage sends the PING command with msg, checks that the server
+// replied with msg and returns the round trip time of the command. Use a
+// message with varied content to detect proxies that corrupt data.
+func PingMessage(c Conn, msg string) (time.Duration, error) {
+	start := time.Now()
+	reply, err := c.Do("PING", msg)
+	d := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	if p, ok := reply.([]byte); !ok || string(p) != msg {
+		return 0, fmt.Errorf("redigo: unexpected reply to PING: %q", reply)
+	}
+	return d, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestPing(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch {
+		case len(args) == 1:
+			return "+PONG\r\n"
+		case args[1] == "corrupt":
+			return "$7\r\ncorrupx\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(args[1]), args[1])
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if d, err := redis.Ping(c); d <= 0 || err != nil {
+		t.Errorf("Ping returned %v, %v, want positive duration", d, err)
+	}
+	if d, err := redis.PingMessage(c, "hello"); d <= 0 || err != nil {
+		t.Errorf("PingMessage returned %v, %v, want positive duration", d, err)
+	}
+	if _, err := redis.PingMessage(c, "corrupt"); err == nil {
+		t.Error("PingMessage with corrupted reply returned nil error")
+	}
+}
+
+func TestPingUnexpectedReply(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string { return "+OK\r\n" })
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	if _, err := redis.Ping(c); err == nil {
+		t.Error("Ping with OK reply returned nil error")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTxAborted is returned by WithTransaction when EXEC returns a nil reply
+// because a watched key was modified.
+var ErrTxAborted = errors.New("redigo: transaction aborted")
+
+// Command is a command and its arguments for use with DoPipeline.
+type Command struct {
+	Cmd  string
+	Args []interface{}
+}
+
+// checkCommands checks the arguments of all commands, so that an invalid
+// command is found before a pipeline is partially written to a connection.
+func checkCommands(cmds []Command) error {
+	for _, cmd := range cmds {
+		if err := checkArgs(cmd.Cmd, cmd.Args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DoPipeline sends the commands to the server in a single pipeline and
+// returns the replies and errors in the order of the commands. An error
+// reply from the server is returned as the error for that command only. If
+// the connection fails, then the error is returned for each command whose
+// reply was not received. If the arguments of a command are invalid, then
+// no command is sent and the error is returned for each command.
+func DoPipeline(c Conn, cmds []Command) ([]interface{}, []error) {
+	replies := make([]interface{}, len(cmds))
+	errs := make([]error, len(cmds))
+
+	fail := func(i int, err error) ([]interface{}, []error) {
+		for ; i < len(errs); i++ {
+			errs[i] = err
+		}
+		return replies, errs
+	}
+
+	if err := checkCommands(cmds); err != nil {
+		return fail(0, err)
+	}
+	for _, cmd := range cmds {
+		if err := c.Send(cmd.Cmd, cmd.Args...); err != nil {
+			return fail(0, err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return fail(0, err)
+	}
+	for i := range cmds {
+		replies[i], errs[i] = c.Receive()
+		if _, ok := errs[i].(Error); errs[i] != nil && !ok {
+			return fail(i, errs[i])
+		}
+	}
+	return replies, errs
+}
+
+// BatchError is returned by BatchDo when a command fails.
+type BatchError struct {
+	// Number of commands that succeeded before the failed command.
+	Succeeded int
+
+	// The error of the failed command.
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("redigo: batch failed after %d commands: %v", e.Succeeded, e.Err)
+}
+
+// BatchDo sends the commands to the server in pipelines of batchSize
+// commands and returns the replies in the order of the commands. The replies
+// to a batch are read before the next batch is sent, which bounds the memory
+// used for buffering commands and replies on the client and the server. If
+// batchSize is not positive, then the commands are sent in a single batch.
+//
+// BatchDo stops at the first command that fails with an error reply or a
+// connection error and returns the replies of the commands that succeeded
+// with a *BatchError. The remaining replies of the failed batch are read so
+// that the connection can be used again after an error reply. If the
+// arguments of a command are invalid, then no command is sent.
+func BatchDo(c Conn, batchSize int, cmds []Command) ([]interface{}, error) {
+	if err := checkCommands(cmds); err != nil {
+		return nil, &BatchError{0, err}
+	}
+	if batchSize <= 0 {
+		batchSize = len(cmds)
+	}
+	replies := make([]interface{}, 0, len(cmds))
+	for start := 0; start < len(cmds); start += batchSize {
+		end := start + batchSize
+		if end > len(cmds) {
+			end = len(cmds)
+		}
+		for _, cmd := range cmds[start:end] {
+			if err := c.Send(cmd.Cmd, cmd.Args...); err != nil {
+				return replies, &BatchError{len(replies), err}
+			}
+		}
+		if err := c.Flush(); err != nil {
+			return replies, &BatchError{len(replies), err}
+		}
+		var firstErr error
+		for i := start; i < end; i++ {
+			reply, err := c.Receive()
+			if _, ok := err.(Error); err != nil && !ok {
+				return replies, &BatchError{len(replies), err}
+			}
+			if firstErr == nil {
+				if err != nil {
+					firstErr = err
+				} else {
+					replies = append(replies, reply)
+				}
+			}
+		}
+		if firstErr != nil {
+			return replies, &BatchError{len(replies), firstErr}
+		}
+	}
+	return replies, nil
+}
+
+// WithTransaction sends MULTI, calls fn to queue commands and executes the
+// transaction with EXEC. If fn returns an error, then the transaction is
+// discarded with DISCARD and the error is returned. Keys watched with WATCH
+// before calling WithTransaction are respected by EXEC. If the transaction
+// is aborted because a watched key was modified, then ErrTxAborted is
+// returned. On success, the replies of the queued commands are returned.
+//
+// If a Send by fn fails, then the transaction is discarded and the error is
+// returned even if fn does not return it, so that the commands queued before
+// the failed command are not executed.
+func WithTransaction(c Conn, fn func(Conn) error) ([]interface{}, error) {
+	if err := c.Send("MULTI"); err != nil {
+		return nil, err
+	}
+	tc := &txConn{Conn: c}
+	err := fn(tc)
+	if err == nil {
+		err = tc.err
+	}
+	if err != nil {
+		c.Do("DISCARD")
+		return nil, err
+	}
+	reply, err := c.Do("EXEC")
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrTxAborted
+	}
+	return Values(reply, nil)
+}
+
+// txConn records the first error returned by Send in a transaction.
+type txConn struct {
+	Conn
+	err error
+}
+
+func (c *txConn) Send(cmd string, args ...interface{}) error {
+	err := c.Conn.Send(cmd, args...)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return err
+}
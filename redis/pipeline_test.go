@@ -0,0 +1,279 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redistest"
+)
+
+func TestDoPipeline(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SET":
+			return "+OK\r\n"
+		case "GET":
+			return "$3\r\nval\r\n"
+		case "QUIT":
+			return "+OK\r\n-ERR bad\r\n*"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	replies, errs := redis.DoPipeline(c, []redis.Command{
+		{"SET", []interface{}{"key", "val"}},
+		{"BOGUS", nil},
+		{"GET", []interface{}{"key"}},
+	})
+	wantReplies := []interface{}{"OK", nil, []byte("val")}
+	if !reflect.DeepEqual(replies, wantReplies) {
+		t.Errorf("replies = %v, want %v", replies, wantReplies)
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("errs = %v, want errors for the second command only", errs)
+	}
+	if errs[1] == nil || errs[1].Error() != "ERR unknown command" {
+		t.Errorf("errs[1] = %v, want ERR unknown command", errs[1])
+	}
+	if c.Err() != nil {
+		t.Fatalf("c.Err() = %v, want nil", c.Err())
+	}
+
+	// A malformed reply breaks the connection. The error is returned for
+	// the commands with no reply.
+	replies, errs = redis.DoPipeline(c, []redis.Command{
+		{"QUIT", nil},
+		{"GET", []interface{}{"key"}},
+		{"GET", []interface{}{"key"}},
+		{"GET", []interface{}{"key"}},
+	})
+	if replies[0] != "OK" || errs[0] != nil {
+		t.Errorf("first reply = %v, %v, want OK", replies[0], errs[0])
+	}
+	if _, ok := errs[1].(redis.Error); !ok {
+		t.Errorf("errs[1] = %v, want server error", errs[1])
+	}
+	if errs[2] == nil || errs[2] != errs[3] || c.Err() == nil {
+		t.Errorf("errs = %v, c.Err() = %v, want connection error", errs, c.Err())
+	}
+}
+
+func TestDoPipelineInvalidArgs(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		sent = append(sent, args[0])
+		mu.Unlock()
+		return "+OK\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	cmds := []redis.Command{
+		{"SET", []interface{}{"a", "1"}},
+		{"SET", []interface{}{"b", nil}},
+	}
+	_, errs := redis.DoPipeline(c, cmds)
+	if errs[0] == nil || errs[0] != errs[1] {
+		t.Errorf("DoPipeline errs = %v, want the argument error for each command", errs)
+	}
+	if _, err := redis.BatchDo(c, 1, cmds); err == nil {
+		t.Error("BatchDo returned nil error")
+	}
+	if _, err := redis.WithTransaction(c, func(c redis.Conn) error {
+		c.Send("SET", "a", "1")
+		c.Send("SET", "b", nil)
+		return nil
+	}); err == nil {
+		t.Error("WithTransaction returned nil error")
+	}
+
+	// Nothing was left buffered to run with the next command.
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("PING returned %v", err)
+	}
+	mu.Lock()
+	if want := []string{"MULTI", "SET", "DISCARD", "PING"}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("server received %q, want %q", sent, want)
+	}
+	mu.Unlock()
+}
+
+// txServer handles MULTI, EXEC, DISCARD and WATCH for a single client.
+type txServer struct {
+	mu      sync.Mutex
+	queued  []string
+	multi   bool
+	watched bool
+	dirty   bool
+	log     []string
+}
+
+func (s *txServer) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, args[0])
+	switch args[0] {
+	case "MULTI":
+		s.multi = true
+		return "+OK\r\n"
+	case "WATCH":
+		s.watched = true
+		return "+OK\r\n"
+	case "DISCARD":
+		s.multi, s.queued, s.watched = false, nil, false
+		return "+OK\r\n"
+	case "EXEC":
+		queued, dirty := s.queued, s.watched && s.dirty
+		s.multi, s.queued, s.watched = false, nil, false
+		if dirty {
+			return "*-1\r\n"
+		}
+		reply := "*" + strconv.Itoa(len(queued)) + "\r\n"
+		for _, r := range queued {
+			reply += r
+		}
+		return reply
+	}
+	if s.multi {
+		s.queued = append(s.queued, ":1\r\n")
+		return "+QUEUED\r\n"
+	}
+	return "+OK\r\n"
+}
+
+func TestWithTransaction(t *testing.T) {
+	tx := &txServer{}
+	s := redis.NewFakeServer(t, tx.handle)
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	replies, err := redis.WithTransaction(c, func(c redis.Conn) error {
+		c.Send("INCR", "a")
+		return c.Send("INCR", "b")
+	})
+	if err != nil || !reflect.DeepEqual(replies, []interface{}{int64(1), int64(1)}) {
+		t.Fatalf("WithTransaction returned %v, %v, want [1 1]", replies, err)
+	}
+
+	errFn := errors.New("fn failed")
+	if _, err := redis.WithTransaction(c, func(c redis.Conn) error {
+		c.Send("INCR", "a")
+		return errFn
+	}); err != errFn {
+		t.Fatalf("WithTransaction returned %v, want %v", err, errFn)
+	}
+	tx.mu.Lock()
+	last, multi := tx.log[len(tx.log)-1], tx.multi
+	tx.mu.Unlock()
+	if last != "DISCARD" || multi {
+		t.Fatalf("last command = %s, in transaction = %v, want DISCARD", last, multi)
+	}
+
+	if _, err := c.Do("WATCH", "a"); err != nil {
+		t.Fatalf("WATCH returned %v", err)
+	}
+	tx.mu.Lock()
+	tx.dirty = true
+	tx.mu.Unlock()
+	if _, err := redis.WithTransaction(c, func(c redis.Conn) error {
+		return c.Send("INCR", "a")
+	}); err != redis.ErrTxAborted {
+		t.Fatalf("WithTransaction on modified watched key returned %v, want %v", err, redis.ErrTxAborted)
+	}
+}
+
+type flushCountConn struct {
+	*redistest.Conn
+	flushes int
+}
+
+func (c *flushCountConn) Flush() error {
+	c.flushes++
+	return c.Conn.Flush()
+}
+
+func TestBatchDo(t *testing.T) {
+	var cmds []redis.Command
+	for i := 0; i < 7; i++ {
+		cmds = append(cmds, redis.Command{Cmd: "SADD", Args: []interface{}{"s", i}})
+	}
+
+	c := &flushCountConn{Conn: redistest.NewConn()}
+	var want []interface{}
+	for i := range cmds {
+		c.AddReply(int64(i), nil)
+		want = append(want, int64(i))
+	}
+	replies, err := redis.BatchDo(c, 3, cmds)
+	if err != nil {
+		t.Fatalf("BatchDo returned %v", err)
+	}
+	if !reflect.DeepEqual(replies, want) {
+		t.Errorf("BatchDo = %v, want %v", replies, want)
+	}
+	if c.flushes != 3 {
+		t.Errorf("BatchDo flushed %d times, want 3", c.flushes)
+	}
+
+	// An error reply stops BatchDo after the failed batch.
+	c = &flushCountConn{Conn: redistest.NewConn()}
+	for i := range cmds {
+		if i == 3 {
+			c.AddReply(nil, redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"))
+		} else {
+			c.AddReply(int64(i), nil)
+		}
+	}
+	replies, err = redis.BatchDo(c, 2, cmds)
+	berr, ok := err.(*redis.BatchError)
+	if !ok || berr.Succeeded != 3 || berr.Err.Error() != "WRONGTYPE Operation against a key holding the wrong kind of value" {
+		t.Fatalf("BatchDo returned error %#v, want BatchError after 3 commands", err)
+	}
+	if !reflect.DeepEqual(replies, want[:3]) {
+		t.Errorf("BatchDo = %v, want %v", replies, want[:3])
+	}
+	if n := len(c.Commands()); n != 4 || c.flushes != 2 {
+		t.Errorf("BatchDo sent %d commands in %d batches, want 4 in 2", n, c.flushes)
+	}
+	// The replies of the failed batch were read.
+	if v, err := c.Do("PING"); err != nil || v != int64(4) {
+		t.Errorf("Do(PING) after BatchDo = %v, %v, want the next scripted reply", v, err)
+	}
+}
@@ -16,7 +16,10 @@ package redis
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
@@ -72,6 +75,12 @@ type Pool struct {
 	// Dial is an application supplied function for creating new connections.
 	Dial func() (Conn, error)
 
+	// DialContext is an application supplied function for creating new
+	// connections. The context is the context given to GetContext, or the
+	// background context for Get. If DialContext is set, then the pool uses
+	// DialContext instead of Dial.
+	DialContext func(ctx context.Context) (Conn, error)
+
 	// TestOnBorrow is an optional application supplied function for checking
 	// the health of an idle connection before the connection is used again by
 	// the application. Argument t is the time that the connection was returned
@@ -79,6 +88,13 @@ type Pool struct {
 	// closed.
 	TestOnBorrow func(c Conn, t time.Time) error
 
+	// If PingOnBorrow is true, then the pool sends a PING command on an idle
+	// connection before the connection is used again by the application. If
+	// the PING fails, then the connection is closed and another idle
+	// connection or a new connection is used. Connections dialed for the
+	// request are not checked. The PING is sent after TestOnBorrow.
+	PingOnBorrow bool
+
 	// Maximum number of idle connections in the pool.
 	MaxIdle int
 
@@ -91,18 +107,68 @@ type Pool struct {
 	// the timeout to a value less than the server's timeout.
 	IdleTimeout time.Duration
 
+	// Interval at which a background goroutine closes connections that
+	// exceeded IdleTimeout or MaxConnLifetime. If the value is zero, then
+	// stale connections are closed only when the application gets a
+	// connection. The goroutine is started when a connection is first
+	// returned to the pool and stopped by Close. Set SweepInterval and
+	// IdleTimeout to release all connections of a pool that is not used. The
+	// pool does not dial connections until the application gets one.
+	SweepInterval time.Duration
+
+	// Close connections older than this duration. If the value is zero, then
+	// the pool does not close connections based on age.
+	MaxConnLifetime time.Duration
+
+	// Database selected on new connections. The pool also selects the
+	// database again when a connection on which the application issued a
+	// SELECT command is returned to the pool. If the value is zero, then the
+	// pool does not issue SELECT commands.
+	DB int
+
+	// If ResetOnPut is true, then the pool clears the state of a connection
+	// with the RESET command when the connection is returned to the pool, so
+	// that connections used for MULTI, SUBSCRIBE or MONITOR can be reused.
+	// The pool selects DB again after RESET. Because RESET also reverts the
+	// protocol, user and client name set when the connection was dialed,
+	// enable ResetOnPut only for connections that do not depend on them. If
+	// RESET fails, for example because the server does not support the
+	// command, then the connection is closed.
+	ResetOnPut bool
+
+	// If Wait is true and the pool is at the MaxActive limit, then Get waits
+	// for a connection to be returned to the pool before returning.
+	Wait bool
+
+	// Maximum time that Get waits for a connection when Wait is true. If
+	// the timeout expires, then Get returns ErrPoolExhausted. If the value
+	// is zero, then Get waits until a connection is available.
+	WaitTimeout time.Duration
+
 	// mu protects fields defined below.
-	mu     sync.Mutex
-	closed bool
-	active int
+	mu           sync.Mutex
+	closed       bool
+	active       int
+	waitCount    int64
+	waitDuration time.Duration
+
+	// Closed by Close to stop the sweeper, and closed by the sweeper when
+	// it returns. Nil if the sweeper was not started.
+	sweepStop chan struct{}
+	sweepDone chan struct{}
+
+	// Closed and cleared when a connection is returned to the pool or a slot
+	// is released. Created on demand by callers waiting for a connection.
+	released chan struct{}
 
 	// Stack of idleConn with most recently used at the front.
 	idle list.List
 }
 
 type idleConn struct {
-	c Conn
-	t time.Time
+	c       Conn
+	t       time.Time // time returned to the pool
+	created time.Time
 }
 
 // NewPool returns a pool that uses newPool to create connections as needed.
@@ -111,11 +177,109 @@ func NewPool(newFn func() (Conn, error), maxIdle int) *Pool {
 	return &Pool{Dial: newFn, MaxIdle: maxIdle}
 }
 
+// PoolOptions specifies the configuration of a pool created with
+// NewPoolWithOptions. The fields have the same meaning as the Pool fields with
+// the same names.
+type PoolOptions struct {
+	Dial            func() (Conn, error)
+	DialContext     func(ctx context.Context) (Conn, error)
+	TestOnBorrow    func(c Conn, t time.Time) error
+	PingOnBorrow    bool
+	MaxIdle         int
+	MaxActive       int
+	IdleTimeout     time.Duration
+	SweepInterval   time.Duration
+	MaxConnLifetime time.Duration
+	DB              int
+	ResetOnPut      bool
+	Wait            bool
+	WaitTimeout     time.Duration
+}
+
+// NewPoolWithOptions returns a pool with the given configuration. Unlike a
+// pool created with a struct literal, the configuration is checked up front
+// and an error describing the first problem found is returned.
+func NewPoolWithOptions(opts PoolOptions) (*Pool, error) {
+	switch {
+	case opts.Dial == nil && opts.DialContext == nil:
+		return nil, errors.New("redigo: pool requires Dial or DialContext")
+	case opts.MaxIdle < 0:
+		return nil, fmt.Errorf("redigo: pool MaxIdle %d is negative", opts.MaxIdle)
+	case opts.MaxActive < 0:
+		return nil, fmt.Errorf("redigo: pool MaxActive %d is negative", opts.MaxActive)
+	case opts.MaxActive > 0 && opts.MaxIdle > opts.MaxActive:
+		return nil, fmt.Errorf("redigo: pool MaxIdle %d is greater than MaxActive %d", opts.MaxIdle, opts.MaxActive)
+	case opts.IdleTimeout < 0:
+		return nil, fmt.Errorf("redigo: pool IdleTimeout %v is negative", opts.IdleTimeout)
+	case opts.SweepInterval < 0:
+		return nil, fmt.Errorf("redigo: pool SweepInterval %v is negative", opts.SweepInterval)
+	case opts.MaxConnLifetime < 0:
+		return nil, fmt.Errorf("redigo: pool MaxConnLifetime %v is negative", opts.MaxConnLifetime)
+	case opts.WaitTimeout < 0:
+		return nil, fmt.Errorf("redigo: pool WaitTimeout %v is negative", opts.WaitTimeout)
+	case opts.WaitTimeout > 0 && !opts.Wait:
+		return nil, errors.New("redigo: pool WaitTimeout requires Wait")
+	case opts.DB < 0:
+		return nil, fmt.Errorf("redigo: pool DB %d is negative", opts.DB)
+	}
+	return &Pool{
+		Dial:            opts.Dial,
+		DialContext:     opts.DialContext,
+		TestOnBorrow:    opts.TestOnBorrow,
+		PingOnBorrow:    opts.PingOnBorrow,
+		MaxIdle:         opts.MaxIdle,
+		MaxActive:       opts.MaxActive,
+		IdleTimeout:     opts.IdleTimeout,
+		SweepInterval:   opts.SweepInterval,
+		MaxConnLifetime: opts.MaxConnLifetime,
+		DB:              opts.DB,
+		ResetOnPut:      opts.ResetOnPut,
+		Wait:            opts.Wait,
+		WaitTimeout:     opts.WaitTimeout,
+	}, nil
+}
+
 // Get gets a connection from the pool.
 func (p *Pool) Get() Conn {
 	return &pooledConnection{p: p}
 }
 
+// GetContext gets a connection from the pool. Unlike Get, the connection is
+// acquired before GetContext returns. If Wait is true and the pool is at the
+// MaxActive limit, then GetContext waits for a connection to be returned to
+// the pool until the context is done and returns the context's error.
+func (p *Pool) GetContext(ctx context.Context) (Conn, error) {
+	ic, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledConnection{p: p, c: ic.c, created: ic.created}, nil
+}
+
+// Do gets a connection from the pool, executes the command and returns the
+// connection to the pool. If a connection cannot be acquired, then the error
+// is returned without executing the command.
+func (p *Pool) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c, err := p.GetContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.Do(cmd, args...)
+}
+
+// DoContext is like Do, but honors the cancellation and deadline of ctx
+// while acquiring the connection and executing the command. The connections
+// created by Pool.Dial must implement ConnWithContext.
+func (p *Pool) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return DoContext(c, ctx, cmd, args...)
+}
+
 // ActiveCount returns the number of active connections in the pool.
 func (p *Pool) ActiveCount() int {
 	p.mu.Lock()
@@ -124,28 +288,213 @@ func (p *Pool) ActiveCount() int {
 	return active
 }
 
+// IdleCount returns the number of idle connections in the pool.
+func (p *Pool) IdleCount() int {
+	p.mu.Lock()
+	idle := p.idle.Len()
+	p.mu.Unlock()
+	return idle
+}
+
+// PoolStats contains pool statistics.
+type PoolStats struct {
+	// ActiveCount is the number of connections in the pool. The count
+	// includes idle connections and connections in use.
+	ActiveCount int
+
+	// IdleCount is the number of idle connections in the pool.
+	IdleCount int
+
+	// WaitCount is the total number of connections waited for.
+	WaitCount int64
+
+	// WaitDuration is the total time blocked waiting for a connection.
+	WaitDuration time.Duration
+}
+
+// Stats returns pool statistics.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	stats := PoolStats{
+		ActiveCount:  p.active,
+		IdleCount:    p.idle.Len(),
+		WaitCount:    p.waitCount,
+		WaitDuration: p.waitDuration,
+	}
+	p.mu.Unlock()
+	return stats
+}
+
+// inUseCount returns the number of connections that have been handed out by
+// the pool and not yet returned.
+func (p *Pool) inUseCount() int {
+	p.mu.Lock()
+	n := p.active - p.idle.Len()
+	p.mu.Unlock()
+	return n
+}
+
 // Close releases the resources used by the pool.
 func (p *Pool) Close() error {
 	p.mu.Lock()
 	idle := p.idle
 	p.idle.Init()
+	alreadyClosed := p.closed
 	p.closed = true
 	p.active -= idle.Len()
+	p.release()
+	sweepStop, sweepDone := p.sweepStop, p.sweepDone
 	p.mu.Unlock()
+	if sweepStop != nil && !alreadyClosed {
+		close(sweepStop)
+		<-sweepDone
+	}
 	for e := idle.Front(); e != nil; e = e.Next() {
 		e.Value.(idleConn).c.Close()
 	}
 	return nil
 }
 
+// Drain closes the pool and waits until the application returns all
+// connections that are in use. After Drain is called, the pool does not hand
+// out connections, idle connections are closed and connections are closed
+// when they are returned. If the context is done before all connections are
+// returned, then Drain returns the context's error and the remaining
+// connections are closed when they are returned.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.Close()
+	p.mu.Lock()
+	for p.active > 0 {
+		if p.released == nil {
+			p.released = make(chan struct{})
+		}
+		released := p.released
+		p.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		p.mu.Lock()
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// startSweeper starts the sweeper goroutine if it is enabled and not
+// running. The caller must hold p.mu.
+func (p *Pool) startSweeper() {
+	if p.SweepInterval <= 0 || p.sweepStop != nil || p.closed {
+		return
+	}
+	if p.IdleTimeout <= 0 && p.MaxConnLifetime <= 0 {
+		return
+	}
+	p.sweepStop = make(chan struct{})
+	p.sweepDone = make(chan struct{})
+	go p.sweeper(p.SweepInterval, p.sweepStop, p.sweepDone)
+}
+
+func (p *Pool) sweeper(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			p.sweep()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sweep closes idle connections that exceeded IdleTimeout or
+// MaxConnLifetime.
+func (p *Pool) sweep() {
+	var stale []Conn
+	p.mu.Lock()
+	now := nowFunc()
+	for e := p.idle.Front(); e != nil; {
+		next := e.Next()
+		ic := e.Value.(idleConn)
+		if (p.IdleTimeout > 0 && !ic.t.Add(p.IdleTimeout).After(now)) ||
+			(p.MaxConnLifetime > 0 && ic.created.Add(p.MaxConnLifetime).Before(now)) {
+			p.idle.Remove(e)
+			p.active -= 1
+			stale = append(stale, ic.c)
+		}
+		e = next
+	}
+	if len(stale) > 0 {
+		p.release()
+	}
+	p.mu.Unlock()
+	for _, c := range stale {
+		c.Close()
+	}
+}
+
 // get prunes stale connections and returns a connection from the idle list or
 // creates a new connection.
-func (p *Pool) get() (Conn, error) {
+func (p *Pool) get(ctx context.Context) (idleConn, error) {
 	p.mu.Lock()
 
+	var start time.Time
+	var timeout <-chan time.Time
+	for {
+		ic, done, err := p.tryGet(ctx)
+		if done {
+			if !start.IsZero() {
+				p.mu.Lock()
+				p.waitCount++
+				p.waitDuration += time.Since(start)
+				p.mu.Unlock()
+			}
+			return ic, err
+		}
+		if start.IsZero() {
+			start = time.Now()
+			if p.WaitTimeout > 0 {
+				t := time.NewTimer(p.WaitTimeout)
+				defer t.Stop()
+				timeout = t.C
+			}
+		}
+
+		// Wait for a connection to be returned to the pool.
+
+		if p.released == nil {
+			p.released = make(chan struct{})
+		}
+		released := p.released
+		p.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.waitCount++
+			p.waitDuration += time.Since(start)
+			p.mu.Unlock()
+			return idleConn{}, ctx.Err()
+		case <-timeout:
+			p.mu.Lock()
+			p.waitCount++
+			p.waitDuration += time.Since(start)
+			p.mu.Unlock()
+			return idleConn{}, ErrPoolExhausted
+		}
+		p.mu.Lock()
+	}
+}
+
+// tryGet is called with p.mu held. If tryGet returns with done set to false,
+// then the pool is at the MaxActive limit, the caller should wait and p.mu is
+// still held. Otherwise p.mu is released.
+func (p *Pool) tryGet(ctx context.Context) (ic idleConn, done bool, err error) {
 	if p.closed {
 		p.mu.Unlock()
-		return nil, errors.New("redigo: get on closed pool")
+		return idleConn{}, true, errors.New("redigo: get on closed pool")
 	}
 
 	// Prune stale connections.
@@ -162,12 +511,31 @@ func (p *Pool) get() (Conn, error) {
 			}
 			p.idle.Remove(e)
 			p.active -= 1
+			p.release()
 			p.mu.Unlock()
 			ic.c.Close()
 			p.mu.Lock()
 		}
 	}
 
+	if lifetime := p.MaxConnLifetime; lifetime > 0 {
+		for e := p.idle.Front(); e != nil; {
+			next := e.Next()
+			ic := e.Value.(idleConn)
+			if ic.created.Add(lifetime).Before(nowFunc()) {
+				p.idle.Remove(e)
+				p.active -= 1
+				p.release()
+				p.mu.Unlock()
+				ic.c.Close()
+				p.mu.Lock()
+				// The list may have changed while the lock was released.
+				next = p.idle.Front()
+			}
+			e = next
+		}
+	}
+
 	// Get idle connection.
 
 	for i, n := 0, p.idle.Len(); i < n; i++ {
@@ -178,66 +546,150 @@ func (p *Pool) get() (Conn, error) {
 		ic := e.Value.(idleConn)
 		p.idle.Remove(e)
 		test := p.TestOnBorrow
+		ping := p.PingOnBorrow
 		p.mu.Unlock()
-		if test == nil || test(ic.c, ic.t) == nil {
-			return ic.c, nil
+		if (test == nil || test(ic.c, ic.t) == nil) && (!ping || pingConn(ic.c) == nil) {
+			return ic, true, nil
 		}
 		ic.c.Close()
 		p.mu.Lock()
 		p.active -= 1
+		p.release()
+		if p.closed {
+			// The pool was closed while the connection was tested.
+			p.mu.Unlock()
+			return idleConn{}, true, errors.New("redigo: get on closed pool")
+		}
 	}
 
 	if p.MaxActive > 0 && p.active >= p.MaxActive {
+		if p.Wait {
+			return idleConn{}, false, nil
+		}
 		p.mu.Unlock()
-		return nil, ErrPoolExhausted
+		return idleConn{}, true, ErrPoolExhausted
 	}
 
 	// No idle connection, create new.
 
-	dial := p.Dial
 	p.active += 1
 	p.mu.Unlock()
-	c, err := dial()
+	ic, err = p.dial(ctx)
+	return ic, true, err
+}
+
+// dial creates a new connection. It is called without p.mu held after
+// p.active is incremented for the connection. On failure, p.active is
+// decremented.
+func (p *Pool) dial(ctx context.Context) (idleConn, error) {
+	var c Conn
+	var err error
+	if p.DialContext != nil {
+		c, err = p.DialContext(ctx)
+	} else {
+		c, err = p.Dial()
+	}
+	created := nowFunc()
+	if err == nil && p.DB != 0 {
+		if _, err = c.Do("SELECT", p.DB); err != nil {
+			c.Close()
+		}
+	}
 	if err != nil {
 		p.mu.Lock()
 		p.active -= 1
+		p.release()
 		p.mu.Unlock()
-		c = nil
+		return idleConn{}, err
+	}
+	return idleConn{c: c, created: created}, nil
+}
+
+// Warmup dials up to n connections and adds them to the idle list, so that
+// the first requests after startup do not wait for connections to be dialed.
+// Warmup stops when the idle list has n connections or MaxIdle connections,
+// or when the pool reaches MaxActive connections. Warmup returns the first
+// dial error. It is safe to call Warmup while the pool is in use.
+func (p *Pool) Warmup(n int) error {
+	for i := 0; i < n; i++ {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return errors.New("redigo: warmup on closed pool")
+		}
+		if p.idle.Len() >= n || p.idle.Len() >= p.MaxIdle || (p.MaxActive > 0 && p.active >= p.MaxActive) {
+			p.mu.Unlock()
+			return nil
+		}
+		p.active += 1
+		p.mu.Unlock()
+		ic, err := p.dial(context.Background())
+		if err != nil {
+			return err
+		}
+		p.put(ic.c, ic.created)
+	}
+	return nil
+}
+
+// release wakes callers waiting for a connection. It is called with p.mu held
+// after a connection is pushed to the idle list or p.active is decremented.
+func (p *Pool) release() {
+	if p.released != nil {
+		close(p.released)
+		p.released = nil
 	}
-	return c, err
 }
 
-func (p *Pool) put(c Conn) error {
-	if c.Err() == nil {
+func (p *Pool) put(c Conn, created time.Time) error {
+	expired := p.MaxConnLifetime > 0 && created.Add(p.MaxConnLifetime).Before(nowFunc())
+	if c.Err() == nil && !expired {
 		p.mu.Lock()
 		if !p.closed {
-			p.idle.PushFront(idleConn{t: nowFunc(), c: c})
+			p.startSweeper()
+			p.idle.PushFront(idleConn{t: nowFunc(), c: c, created: created})
 			if p.idle.Len() > p.MaxIdle {
 				c = p.idle.Remove(p.idle.Back()).(idleConn).c
 			} else {
 				c = nil
+				p.release()
 			}
 		}
 		p.mu.Unlock()
 	}
 	if c != nil {
-		p.mu.Lock()
-		p.active -= 1
-		p.mu.Unlock()
-		return c.Close()
+		return p.discard(c)
 	}
 	return nil
 }
 
+func pingConn(c Conn) error {
+	_, err := c.Do("PING")
+	return err
+}
+
+// discard closes a connection that is not returned to the idle list.
+func (p *Pool) discard(c Conn) error {
+	p.mu.Lock()
+	p.active -= 1
+	p.release()
+	p.mu.Unlock()
+	return c.Close()
+}
+
 type pooledConnection struct {
-	c   Conn
-	err error
-	p   *Pool
+	c        Conn
+	created  time.Time
+	err      error
+	p        *Pool
+	selected bool // application issued SELECT
 }
 
 func (c *pooledConnection) get() error {
 	if c.err == nil && c.c == nil {
-		c.c, c.err = c.p.get()
+		var ic idleConn
+		ic, c.err = c.p.get(context.Background())
+		c.c, c.created = ic.c, ic.created
 	}
 	return c.err
 }
@@ -245,7 +697,11 @@ func (c *pooledConnection) get() error {
 func (c *pooledConnection) Close() (err error) {
 	if c.c != nil {
 		c.c.Do("")
-		c.p.put(c.c)
+		if err := c.resetState(); err != nil {
+			c.p.discard(c.c)
+		} else {
+			c.p.put(c.c, c.created)
+		}
 		c.c = nil
 		c.err = errPoolClosed
 	}
@@ -263,16 +719,56 @@ func (c *pooledConnection) Do(commandName string, args ...interface{}) (reply in
 	if err := c.get(); err != nil {
 		return nil, err
 	}
+	c.checkSelect(commandName)
 	return c.c.Do(commandName, args...)
 }
 
+func (c *pooledConnection) DoContext(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if err := c.get(); err != nil {
+		return nil, err
+	}
+	c.checkSelect(commandName)
+	return DoContext(c.c, ctx, commandName, args...)
+}
+
+func (c *pooledConnection) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if err := c.get(); err != nil {
+		return nil, err
+	}
+	c.checkSelect(commandName)
+	return DoWithTimeout(c.c, timeout, commandName, args...)
+}
+
 func (c *pooledConnection) Send(commandName string, args ...interface{}) error {
 	if err := c.get(); err != nil {
 		return err
 	}
+	c.checkSelect(commandName)
 	return c.c.Send(commandName, args...)
 }
 
+// resetState resets the connection if the pool is configured to do so and
+// selects the pool's database if the application or RESET selected another.
+func (c *pooledConnection) resetState() error {
+	if c.p.ResetOnPut {
+		if err := Reset(c.c); err != nil {
+			return err
+		}
+		c.selected = true
+	}
+	if !c.selected || c.p.DB == 0 {
+		return nil
+	}
+	_, err := c.c.Do("SELECT", c.p.DB)
+	return err
+}
+
+func (c *pooledConnection) checkSelect(commandName string) {
+	if strings.EqualFold(commandName, "SELECT") {
+		c.selected = true
+	}
+}
+
 func (c *pooledConnection) Flush() error {
 	if err := c.get(); err != nil {
 		return err
@@ -286,3 +782,10 @@ func (c *pooledConnection) Receive() (reply interface{}, err error) {
 	}
 	return c.c.Receive()
 }
+
+func (c *pooledConnection) ReceiveWithTimeout(timeout time.Duration) (reply interface{}, err error) {
+	if err := c.get(); err != nil {
+		return nil, err
+	}
+	return ReceiveWithTimeout(c.c, timeout)
+}
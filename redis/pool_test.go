@@ -15,7 +15,12 @@
 package redis
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -200,6 +205,166 @@ func TestPoolTimeout(t *testing.T) {
 	d.check("2", p, 2, 1)
 }
 
+// lockedConn serializes Close with the dialer, so that the sweeper can
+// close connections while the test dials.
+type lockedConn struct {
+	Conn
+	mu *sync.Mutex
+}
+
+func (c lockedConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func TestPoolScaleToZero(t *testing.T) {
+	d := dialer{t: t}
+	var mu sync.Mutex
+	p := &Pool{
+		MaxIdle:       4,
+		IdleTimeout:   20 * time.Millisecond,
+		SweepInterval: 5 * time.Millisecond,
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c, err := d.dial()
+			return lockedConn{c, &mu}, err
+		},
+	}
+	defer p.Close()
+
+	// The pool does not dial until a connection is used.
+	c := p.Get()
+	if n := p.IdleCount(); n != 0 {
+		t.Errorf("IdleCount() = %d before use, want 0", n)
+	}
+	d.check("before use", p, 0, 0)
+	c.Close()
+
+	conns := []Conn{p.Get(), p.Get(), p.Get()}
+	for _, c := range conns {
+		c.Do("PING")
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	if n := p.IdleCount(); n != 3 {
+		t.Errorf("IdleCount() = %d after use, want 3", n)
+	}
+
+	// The sweeper closes the connections after IdleTimeout.
+	open := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return d.open
+	}
+	deadline := time.Now().Add(time.Second)
+	for (p.IdleCount() != 0 || open() != 0) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := p.IdleCount(); n != 0 {
+		t.Errorf("IdleCount() = %d after idle window, want 0", n)
+	}
+	mu.Lock()
+	d.check("after idle window", p, 3, 0)
+	mu.Unlock()
+}
+
+func TestPoolSweeper(t *testing.T) {
+	d := dialer{t: t}
+	var mu sync.Mutex
+	p := &Pool{
+		MaxIdle:       2,
+		IdleTimeout:   20 * time.Millisecond,
+		SweepInterval: 5 * time.Millisecond,
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c, err := d.dial()
+			return lockedConn{c, &mu}, err
+		},
+	}
+	c1, c2 := p.Get(), p.Get()
+	c1.Do("PING")
+	c2.Do("PING")
+	c1.Close()
+	c2.Close()
+
+	// The sweeper closes the idle connections without a call to Get.
+	deadline := time.Now().Add(time.Second)
+	for p.ActiveCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := p.ActiveCount(); n != 0 {
+		t.Errorf("ActiveCount() = %d, want idle connections closed by the sweeper", n)
+	}
+
+	// Concurrent use of the pool while the sweeper runs.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				c := p.Get()
+				c.Do("PING")
+				c.Close()
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	p.Close()
+	select {
+	case <-p.sweepDone:
+	default:
+		t.Error("sweeper running after Close")
+	}
+	p.Close()
+	d.check("after close", p, d.dialed, 0)
+}
+
+func TestPoolMaxConnLifetime(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{
+		MaxIdle:         2,
+		MaxConnLifetime: 300 * time.Second,
+		Dial:            d.dial,
+	}
+
+	now := time.Now()
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	c1 := p.Get()
+	c1.Do("PING")
+	now = now.Add(200 * time.Second)
+	c2 := p.Get()
+	c2.Do("PING")
+	c1.Close()
+	c2.Close()
+
+	d.check("1", p, 2, 2)
+
+	// The first connection is pruned from the idle list.
+	now = now.Add(150 * time.Second)
+	c := p.Get()
+	c.Do("PING")
+	d.check("2", p, 2, 1)
+
+	// The second connection is closed when returned to the pool.
+	now = now.Add(200 * time.Second)
+	c.Close()
+	d.check("3", p, 2, 0)
+
+	c = p.Get()
+	c.Do("PING")
+	c.Close()
+	d.check("4", p, 3, 1)
+}
+
 func TestBorrowCheck(t *testing.T) {
 	d := dialer{t: t}
 	p := &Pool{
@@ -216,6 +381,94 @@ func TestBorrowCheck(t *testing.T) {
 	d.check("1", p, 10, 1)
 }
 
+func TestPoolPingOnBorrow(t *testing.T) {
+	var mu sync.Mutex
+	var cmds []string
+	s := NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmds = append(cmds, args[0])
+		mu.Unlock()
+		return "+OK\r\n"
+	})
+	defer s.Close()
+	dialed := 0
+	p := &Pool{
+		MaxIdle:      1,
+		PingOnBorrow: true,
+		Dial: func() (Conn, error) {
+			dialed++
+			return Dial("tcp", s.Addr())
+		},
+	}
+	defer p.Close()
+	do := func() {
+		c := p.Get()
+		defer c.Close()
+		if _, err := c.Do("GET"); err != nil {
+			t.Fatalf("Do returned %v", err)
+		}
+	}
+
+	do() // new connection, not pinged
+	do() // idle connection, pinged
+	s.DropConns()
+	do() // idle connection fails the ping and is replaced
+	mu.Lock()
+	if got, want := strings.Join(cmds, " "), "GET PING GET GET"; got != want {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	mu.Unlock()
+	if dialed != 2 {
+		t.Errorf("dialed = %d, want 2", dialed)
+	}
+	if n := p.ActiveCount(); n != 1 {
+		t.Errorf("ActiveCount() = %d, want 1", n)
+	}
+}
+
+func TestBorrowCheckReplacesBrokenConn(t *testing.T) {
+	d := dialer{t: t}
+	var broken Conn
+	var returned time.Time
+	p := &Pool{
+		MaxIdle: 2,
+		Dial:    d.dial,
+		TestOnBorrow: func(c Conn, tr time.Time) error {
+			if !tr.Equal(returned) {
+				t.Errorf("TestOnBorrow t=%v, want time returned %v", tr, returned)
+			}
+			if c == broken {
+				return Error("BROKEN")
+			}
+			return nil
+		},
+	}
+
+	now := time.Now()
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	c := p.Get()
+	c.Do("PING")
+	broken = c.(*pooledConnection).c
+	returned = now
+	c.Close()
+	d.check("1", p, 1, 1)
+
+	now = now.Add(time.Second)
+	c = p.Get()
+	if _, err := c.Do("PING"); err != nil {
+		t.Fatalf("Do returned %v", err)
+	}
+	if c.(*pooledConnection).c == broken {
+		t.Fatal("Get returned the broken connection")
+	}
+	// The broken connection is closed and replaced by a new connection.
+	d.check("2", p, 2, 1)
+	c.Close()
+	d.check("3", p, 2, 1)
+}
+
 func TestMaxActive(t *testing.T) {
 	d := dialer{t: t}
 	p := &Pool{
@@ -248,3 +501,489 @@ func TestMaxActive(t *testing.T) {
 
 	d.check("2", p, 2, 2)
 }
+
+func TestPoolDo(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{MaxIdle: 1, MaxActive: 1, Dial: d.dial}
+	for i := 0; i < 3; i++ {
+		if _, err := p.Do("PING"); err != nil {
+			t.Fatalf("Do returned %v", err)
+		}
+	}
+	d.check("1", p, 1, 1)
+	if n := p.inUseCount(); n != 0 {
+		t.Errorf("inUseCount() = %d, want 0", n)
+	}
+
+	c := p.Get()
+	c.Do("PING")
+	if _, err := p.Do("PING"); err != ErrPoolExhausted {
+		t.Errorf("Do on exhausted pool returned %v, want %v", err, ErrPoolExhausted)
+	}
+	c.Close()
+
+	s := NewFakeServer(t, func(args []string) string { return "+OK\r\n" })
+	defer s.Close()
+	p = &Pool{MaxIdle: 1, Dial: func() (Conn, error) { return Dial("tcp", s.Addr()) }}
+	defer p.Close()
+	if v, err := p.DoContext(context.Background(), "PING"); v != "OK" || err != nil {
+		t.Errorf("DoContext returned %v, %v, want OK", v, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.DoContext(ctx, "PING"); err != context.Canceled {
+		t.Errorf("DoContext with cancelled context returned %v, want %v", err, context.Canceled)
+	}
+	if n := p.inUseCount(); n != 0 {
+		t.Errorf("inUseCount() = %d, want 0", n)
+	}
+}
+
+func TestPoolWaitTimeout(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{
+		MaxIdle:     1,
+		MaxActive:   1,
+		Wait:        true,
+		WaitTimeout: 50 * time.Millisecond,
+		Dial:        d.dial,
+	}
+	defer p.Close()
+	c := p.Get()
+	c.Do("PING")
+
+	start := time.Now()
+	c2 := p.Get()
+	_, err := c2.Do("PING")
+	elapsed := time.Since(start)
+	if err != ErrPoolExhausted {
+		t.Fatalf("Get on exhausted pool returned %v, want %v", err, ErrPoolExhausted)
+	}
+	c2.Close()
+	if elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Get returned after %v, want about 50ms", elapsed)
+	}
+	if stats := p.Stats(); stats.WaitCount != 1 {
+		t.Errorf("WaitCount = %d, want 1", stats.WaitCount)
+	}
+
+	// A connection returned before the timeout is handed to the waiter.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.Close()
+	}()
+	c3, err := p.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	c3.Close()
+	d.check("1", p, 1, 1)
+}
+
+type ctxKey struct{}
+
+func TestPoolDialContext(t *testing.T) {
+	d := dialer{t: t}
+	var got interface{}
+	p := &Pool{
+		MaxIdle: 1,
+		Dial: func() (Conn, error) {
+			t.Error("Dial called, want DialContext")
+			return d.dial()
+		},
+		DialContext: func(ctx context.Context) (Conn, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			got = ctx.Value(ctxKey{})
+			return d.dial()
+		},
+	}
+	defer p.Close()
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	if got != "v" {
+		t.Errorf("DialContext got context value %v, want v", got)
+	}
+	c.Close()
+	d.check("1", p, 1, 1)
+
+	// The idle connection is used without dialing.
+	ctx, cancel := context.WithCancel(context.Background())
+	if c, err = p.GetContext(ctx); err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	c2, err := p.GetContext(ctx)
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	c.Close()
+	c2.Close()
+	d.check("2", p, 2, 1)
+
+	cancel()
+	p.Close()
+	p = &Pool{DialContext: p.DialContext}
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Errorf("GetContext with cancelled context returned %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestNewPoolWithOptions(t *testing.T) {
+	d := dialer{t: t}
+	p, err := NewPoolWithOptions(PoolOptions{Dial: d.dial, MaxIdle: 2, MaxActive: 4, Wait: true, WaitTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewPoolWithOptions returned %v", err)
+	}
+	if p.MaxIdle != 2 || p.MaxActive != 4 || !p.Wait || p.WaitTimeout != time.Second {
+		t.Errorf("NewPoolWithOptions returned pool with %+v", p)
+	}
+	c := p.Get()
+	if _, err := c.Do("PING"); err != nil {
+		t.Errorf("Do returned %v", err)
+	}
+	c.Close()
+	p.Close()
+
+	tests := []struct {
+		opts PoolOptions
+		want string
+	}{
+		{PoolOptions{}, "Dial"},
+		{PoolOptions{Dial: d.dial, MaxIdle: -1}, "MaxIdle"},
+		{PoolOptions{Dial: d.dial, MaxActive: -1}, "MaxActive"},
+		{PoolOptions{Dial: d.dial, MaxIdle: 3, MaxActive: 2}, "greater than MaxActive"},
+		{PoolOptions{Dial: d.dial, IdleTimeout: -time.Second}, "IdleTimeout"},
+		{PoolOptions{Dial: d.dial, SweepInterval: -time.Second}, "SweepInterval"},
+		{PoolOptions{Dial: d.dial, MaxConnLifetime: -time.Second}, "MaxConnLifetime"},
+		{PoolOptions{Dial: d.dial, Wait: true, WaitTimeout: -time.Second}, "WaitTimeout"},
+		{PoolOptions{Dial: d.dial, WaitTimeout: time.Second}, "requires Wait"},
+		{PoolOptions{Dial: d.dial, DB: -1}, "DB"},
+	}
+	for _, tt := range tests {
+		p, err := NewPoolWithOptions(tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewPoolWithOptions(%+v) returned %v, want error containing %q", tt.opts, err, tt.want)
+		}
+		if p != nil {
+			t.Errorf("NewPoolWithOptions(%+v) returned non-nil pool", tt.opts)
+		}
+	}
+}
+
+func TestPoolGetContext(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{
+		MaxIdle:   1,
+		MaxActive: 1,
+		Wait:      true,
+		Dial:      d.dial,
+	}
+	c1, err := p.GetContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetContext returned %v", err)
+	}
+	d.check("1", p, 1, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext on exhausted pool returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error)
+	go func() {
+		c, err := p.GetContext(context.Background())
+		if err == nil {
+			c.Close()
+		}
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c1.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("GetContext after close returned %v", err)
+	}
+	d.check("2", p, 1, 1)
+
+	// Closing a new connection that is not kept idle releases its slot.
+	p.Close()
+	p = &Pool{MaxActive: 1, Wait: true, Dial: d.dial}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		c, err := p.GetContext(ctx)
+		if err != nil {
+			t.Fatalf("GetContext returned %v", err)
+		}
+		c.Close()
+	}
+	d.check("3", p, 4, 0)
+}
+
+func TestPoolWait(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{
+		MaxIdle:   1,
+		MaxActive: 1,
+		Wait:      true,
+		Dial:      d.dial,
+	}
+	c1 := p.Get()
+	c1.Do("PING")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c1.Close()
+	}()
+	c2 := p.Get()
+	if _, err := c2.Do("PING"); err != nil {
+		t.Fatalf("Do on waiting connection returned %v", err)
+	}
+	c2.Close()
+	d.check("1", p, 1, 1)
+
+	stats := p.Stats()
+	if stats.ActiveCount != 1 || stats.IdleCount != 1 {
+		t.Errorf("stats = %+v, want ActiveCount=1 IdleCount=1", stats)
+	}
+	if stats.WaitCount != 1 || stats.WaitDuration <= 0 {
+		t.Errorf("stats = %+v, want WaitCount=1 and positive WaitDuration", stats)
+	}
+
+	c1 = p.Get()
+	c1.Do("PING")
+	if stats := p.Stats(); stats.IdleCount != 0 || stats.WaitCount != 1 {
+		t.Errorf("stats = %+v, want IdleCount=0 WaitCount=1", stats)
+	}
+	c1.Close()
+}
+
+// recordConn records the commands sent to the connection.
+type recordConn struct {
+	fakeConn
+	cmds     *[]string
+	resetErr error
+}
+
+func (c *recordConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "" {
+		return nil, nil
+	}
+	*c.cmds = append(*c.cmds, strings.TrimSpace(fmt.Sprintln(append([]interface{}{commandName}, args...)...)))
+	if commandName == "SELECT" && args[0] == 99 {
+		return nil, Error("ERR invalid DB index")
+	}
+	if commandName == "RESET" {
+		if c.resetErr != nil {
+			return nil, c.resetErr
+		}
+		return "RESET", nil
+	}
+	return nil, nil
+}
+
+func TestPoolDB(t *testing.T) {
+	var cmds []string
+	open := 0
+	p := &Pool{
+		MaxIdle: 1,
+		DB:      3,
+		Dial: func() (Conn, error) {
+			open += 1
+			return &recordConn{fakeConn: fakeConn{open: &open}, cmds: &cmds}, nil
+		},
+	}
+
+	c := p.Get()
+	c.Do("GET", "a")
+	c.Close()
+	c = p.Get()
+	c.Do("select", 5)
+	c.Do("GET", "b")
+	c.Close()
+	c = p.Get()
+	c.Do("GET", "c")
+	c.Close()
+
+	want := "[SELECT 3 GET a select 5 GET b SELECT 3 GET c]"
+	if got := fmt.Sprint(cmds); got != want {
+		t.Errorf("commands = %s, want %s", got, want)
+	}
+	if open != 1 {
+		t.Errorf("open=%d, want 1", open)
+	}
+
+	p.Close()
+	p = &Pool{
+		DB:   99,
+		Dial: p.Dial,
+	}
+	c = p.Get()
+	if _, err := c.Do("GET", "a"); err == nil || err.Error() != "ERR invalid DB index" {
+		t.Errorf("Do on connection with failed SELECT returned %v", err)
+	}
+	c.Close()
+	if open != 0 || p.ActiveCount() != 0 {
+		t.Errorf("open=%d active=%d after failed SELECT, want 0", open, p.ActiveCount())
+	}
+}
+
+func TestPoolResetOnPut(t *testing.T) {
+	var cmds []string
+	var resetErr error
+	open := 0
+	p := &Pool{
+		MaxIdle:    1,
+		DB:         3,
+		ResetOnPut: true,
+		Dial: func() (Conn, error) {
+			open += 1
+			return &recordConn{fakeConn: fakeConn{open: &open}, cmds: &cmds, resetErr: resetErr}, nil
+		},
+	}
+	defer p.Close()
+
+	c := p.Get()
+	c.Do("MULTI")
+	c.Close()
+	c = p.Get()
+	c.Do("GET", "a")
+	c.Close()
+
+	want := "[SELECT 3 MULTI RESET SELECT 3 GET a RESET SELECT 3]"
+	if got := fmt.Sprint(cmds); got != want {
+		t.Errorf("commands = %s, want %s", got, want)
+	}
+	if open != 1 {
+		t.Errorf("open=%d, want 1", open)
+	}
+
+	// The connection is closed if the server does not support RESET.
+	p.Close()
+	cmds = nil
+	resetErr = Error("ERR unknown command 'RESET'")
+	p = &Pool{MaxIdle: 1, ResetOnPut: true, Dial: p.Dial}
+	c = p.Get()
+	c.Do("GET", "a")
+	c.Close()
+	if open != 0 || p.ActiveCount() != 0 {
+		t.Errorf("open=%d active=%d after failed RESET, want 0", open, p.ActiveCount())
+	}
+}
+
+func TestPoolWarmup(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{
+		MaxIdle:   3,
+		MaxActive: 4,
+		Dial:      d.dial,
+	}
+	defer p.Close()
+
+	if err := p.Warmup(2); err != nil {
+		t.Fatalf("Warmup returned %v", err)
+	}
+	d.check("warmup 2", p, 2, 2)
+
+	// Warmup is bounded by MaxIdle.
+	if err := p.Warmup(10); err != nil {
+		t.Fatalf("Warmup returned %v", err)
+	}
+	d.check("warmup 10", p, 3, 3)
+
+	// The warm connections are used without dialing.
+	c1, c2 := p.Get(), p.Get()
+	c1.Do("PING")
+	c2.Do("PING")
+	d.check("get", p, 3, 3)
+
+	// Warmup is bounded by MaxActive.
+	if err := p.Warmup(3); err != nil {
+		t.Fatalf("Warmup returned %v", err)
+	}
+	d.check("warmup with active", p, 4, 4)
+	c1.Close()
+	c2.Close()
+
+	errDial := errors.New("dial failed")
+	p2 := &Pool{
+		MaxIdle: 2,
+		Dial:    func() (Conn, error) { return nil, errDial },
+	}
+	if err := p2.Warmup(2); err != errDial {
+		t.Errorf("Warmup returned %v, want %v", err, errDial)
+	}
+	if n := p2.ActiveCount(); n != 0 {
+		t.Errorf("ActiveCount() = %d after failed warmup, want 0", n)
+	}
+	p2.Close()
+	if err := p2.Warmup(1); err == nil {
+		t.Error("Warmup on closed pool returned nil error")
+	}
+}
+
+func TestPoolDrain(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{
+		MaxIdle: 2,
+		Dial:    d.dial,
+	}
+
+	c1, c2 := p.Get(), p.Get()
+	c1.Do("PING")
+	c2.Do("PING")
+	d.check("before drain", p, 2, 2)
+
+	done := make(chan error, 1)
+	go func() { done <- p.Drain(context.Background()) }()
+
+	for {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// New connections are not handed out while draining.
+	if _, err := p.Get().Do("PING"); err == nil {
+		t.Error("Get while draining returned a usable connection")
+	}
+
+	c1.Close()
+	select {
+	case err := <-done:
+		t.Fatalf("Drain returned %v with a connection in use", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	c2.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Drain returned %v", err)
+	}
+	if d.open != 0 || p.ActiveCount() != 0 {
+		t.Errorf("open=%d active=%d after drain, want 0", d.open, p.ActiveCount())
+	}
+}
+
+func TestPoolDrainTimeout(t *testing.T) {
+	d := dialer{t: t}
+	p := &Pool{
+		MaxIdle: 2,
+		Dial:    d.dial,
+	}
+
+	c := p.Get()
+	c.Do("PING")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Drain returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	c.Close()
+	d.check("after close", p, 1, 0)
+}
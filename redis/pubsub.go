@@ -16,6 +16,10 @@ package redis
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 // Subscribe represents a subscribe or unsubscribe notification.
@@ -54,9 +58,90 @@ type PMessage struct {
 	Data []byte
 }
 
+// Pong represents a pubsub pong notification.
+type Pong struct {
+	Data string
+}
+
 // PubSubConn wraps a Conn with convenience methods for subscribers.
+//
+// A PubSubConn created with NewPubSubConn tracks the channels and patterns
+// that the connection is subscribed to as subscription notifications are
+// received.
 type PubSubConn struct {
 	Conn Conn
+
+	state *pubSubState
+}
+
+type pubSubState struct {
+	mu       sync.Mutex
+	channels map[string]bool
+	patterns map[string]bool
+
+	// Notifications received by SubscribeAndWait and not returned by
+	// Receive yet.
+	held []interface{}
+}
+
+// NewPubSubConn returns a PubSubConn for the connection that tracks the
+// subscribed channels and patterns.
+func NewPubSubConn(c Conn) PubSubConn {
+	return PubSubConn{Conn: c, state: &pubSubState{
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+	}}
+}
+
+// Channels returns the sorted list of channels that the connection is
+// subscribed to as of the last subscription notification returned by
+// Receive. Channels returns nil if the PubSubConn was not created with
+// NewPubSubConn.
+func (c PubSubConn) Channels() []string {
+	if c.state == nil {
+		return nil
+	}
+	return c.state.list(c.state.channels)
+}
+
+// Patterns returns the sorted list of patterns that the connection is
+// subscribed to as of the last subscription notification returned by
+// Receive. Patterns returns nil if the PubSubConn was not created with
+// NewPubSubConn.
+func (c PubSubConn) Patterns() []string {
+	if c.state == nil {
+		return nil
+	}
+	return c.state.list(c.state.patterns)
+}
+
+func (s *pubSubState) list(m map[string]bool) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(m) == 0 {
+		return nil
+	}
+	l := make([]string, 0, len(m))
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func (s *pubSubState) update(n Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch n.Kind {
+	case "subscribe":
+		s.channels[n.Channel] = true
+	case "unsubscribe":
+		delete(s.channels, n.Channel)
+	case "psubscribe":
+		s.patterns[n.Channel] = true
+	case "punsubscribe":
+		delete(s.patterns, n.Channel)
+	}
 }
 
 // Close closes the connection.
@@ -70,6 +155,64 @@ func (c PubSubConn) Subscribe(channel ...interface{}) error {
 	return c.Conn.Flush()
 }
 
+// SubscribeAndWait subscribes the connection to the specified channels and
+// waits until the server confirms the subscription to every channel. Other
+// notifications received while waiting, such as messages on channels that
+// the connection is already subscribed to, are returned by later calls to
+// Receive in the order received. SubscribeAndWait returns an error if the
+// PubSubConn was not created with NewPubSubConn.
+func (c PubSubConn) SubscribeAndWait(channel ...interface{}) error {
+	if c.state == nil {
+		return errors.New("redigo: SubscribeAndWait requires a PubSubConn created with NewPubSubConn")
+	}
+	waiting := make(map[string]int)
+	for _, ch := range channel {
+		switch ch := ch.(type) {
+		case []byte:
+			waiting[string(ch)]++
+		default:
+			waiting[fmt.Sprint(ch)]++
+		}
+	}
+	if err := c.Subscribe(channel...); err != nil {
+		return err
+	}
+	for n := len(channel); n > 0; {
+		switch v := c.receiveReply(c.Conn.Receive()).(type) {
+		case error:
+			return v
+		case Subscription:
+			if v.Kind == "subscribe" && waiting[v.Channel] > 0 {
+				waiting[v.Channel]--
+				n--
+				continue
+			}
+			c.state.hold(v)
+		default:
+			c.state.hold(v)
+		}
+	}
+	return nil
+}
+
+func (s *pubSubState) hold(n interface{}) {
+	s.mu.Lock()
+	s.held = append(s.held, n)
+	s.mu.Unlock()
+}
+
+// next returns a notification held by SubscribeAndWait, if any.
+func (s *pubSubState) next() (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.held) == 0 {
+		return nil, false
+	}
+	n := s.held[0]
+	s.held = s.held[1:]
+	return n, true
+}
+
 // PSubscribe subscribes the connection to the given patterns.
 func (c PubSubConn) PSubscribe(channel ...interface{}) error {
 	c.Conn.Send("PSUBSCRIBE", channel...)
@@ -90,11 +233,42 @@ func (c PubSubConn) PUnsubscribe(channel ...interface{}) error {
 	return c.Conn.Flush()
 }
 
-// Receive returns a pushed message as a Subscription, Message, PMessage or
-// error. The return value is intended to be used directly in a type switch as
+// UnsubscribeAll unsubscribes the connection from all channels and patterns.
+func (c PubSubConn) UnsubscribeAll() error {
+	c.Conn.Send("UNSUBSCRIBE")
+	c.Conn.Send("PUNSUBSCRIBE")
+	return c.Conn.Flush()
+}
+
+// Ping sends a PING to the server with the specified data. The server
+// replies with a Pong notification that Receive returns in order with the
+// other notifications. Use Ping to check the health of a connection during a
+// long silence. While the connection is subscribed to channels or patterns,
+// PING is the only command other than the subscribe and unsubscribe commands
+// that the server permits.
+func (c PubSubConn) Ping(data string) error {
+	c.Conn.Send("PING", data)
+	return c.Conn.Flush()
+}
+
+// Receive returns a pushed message as a Subscription, Message, PMessage, Pong
+// or error. The return value is intended to be used directly in a type switch as
 // illustrated in the PubSubConn example.
 func (c PubSubConn) Receive() interface{} {
-	reply, err := Values(c.Conn.Receive())
+	if c.state != nil {
+		if n, ok := c.state.next(); ok {
+			return n
+		}
+	}
+	return c.receiveReply(c.Conn.Receive())
+}
+
+func (c PubSubConn) receiveReply(r interface{}, err error) interface{} {
+	if r == pongReply {
+		// PING on a connection that has no subscriptions.
+		return Pong{}
+	}
+	reply, err := Values(r, err)
 	if err != nil {
 		return err
 	}
@@ -123,7 +297,87 @@ func (c PubSubConn) Receive() interface{} {
 		if _, err := Scan(reply, &s.Channel, &s.Count); err != nil {
 			return err
 		}
+		if c.state != nil {
+			c.state.update(s)
+		}
 		return s
+	case "pong":
+		var p Pong
+		if _, err := Scan(reply, &p.Data); err != nil {
+			return err
+		}
+		return p
 	}
 	return errors.New("redigo: unknown pubsub notification")
 }
+
+// ReceiveWithTimeout is like Receive, but it allows the application to
+// override the connection's default timeout. If no notification arrives
+// before the timeout, then ReceiveWithTimeout returns an error with a
+// Timeout method that reports true and the connection can be used again.
+func (c PubSubConn) ReceiveWithTimeout(timeout time.Duration) interface{} {
+	if c.state != nil {
+		if n, ok := c.state.next(); ok {
+			return n
+		}
+	}
+	return c.receiveReply(ReceiveWithTimeout(c.Conn, timeout))
+}
+
+// How often a goroutine started by ReceiveChan that is blocked on sending
+// checks whether the connection is closed.
+var receiveChanPollInterval = 100 * time.Millisecond
+
+// ReceiveChan is like ReceiveChanSize with unbuffered channels.
+func (c PubSubConn) ReceiveChan() (<-chan interface{}, <-chan error) {
+	return c.ReceiveChanSize(0)
+}
+
+// ReceiveChanSize starts a goroutine that receives pushed notifications and
+// sends the Subscription, Message, PMessage and Pong values to the first
+// returned channel and errors to the second. The notification channel has
+// the given buffer size. The error channel has room for one more error so
+// that the error that broke the connection is not lost. Both channels are
+// closed and the goroutine exits when the connection is broken or closed.
+func (c PubSubConn) ReceiveChanSize(size int) (<-chan interface{}, <-chan error) {
+	notifications := make(chan interface{}, size)
+	errs := make(chan error, size+1)
+	go func() {
+		defer close(notifications)
+		defer close(errs)
+		ticker := time.NewTicker(receiveChanPollInterval)
+		defer ticker.Stop()
+		for {
+			n := c.Receive()
+			err, isErr := n.(error)
+			if isErr && c.Conn.Err() != nil {
+				// The connection is broken or closed. The error is dropped
+				// if the error channel is full.
+				select {
+				case errs <- err:
+				default:
+				}
+				return
+			}
+			for sent := false; !sent; {
+				if isErr {
+					select {
+					case errs <- err:
+						sent = true
+					case <-ticker.C:
+					}
+				} else {
+					select {
+					case notifications <- n:
+						sent = true
+					case <-ticker.C:
+					}
+				}
+				if !sent && c.Conn.Err() != nil {
+					return
+				}
+			}
+		}
+	}()
+	return notifications, errs
+}
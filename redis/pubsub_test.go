@@ -19,6 +19,7 @@ import (
 	"github.com/garyburd/redigo/redis"
 	"net"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -43,7 +44,7 @@ func ExamplePubSubConn() {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	psc := redis.PubSubConn{c}
+	psc := redis.NewPubSubConn(c)
 
 	// This goroutine receives and prints pushed notifications from the server.
 	// The goroutine exits when the connection is unsubscribed from all
@@ -119,7 +120,7 @@ func TestPushed(t *testing.T) {
 	defer nc.Close()
 	nc.SetReadDeadline(time.Now().Add(4 * time.Second))
 
-	c := redis.PubSubConn{redis.NewConn(nc, 0, 0)}
+	c := redis.NewPubSubConn(redis.NewConn(nc, 0, 0))
 
 	c.Subscribe("c1")
 	expectPushed(t, c, "Subscribe(c1)", redis.Subscription{"subscribe", "c1", 1})
@@ -136,3 +137,241 @@ func TestPushed(t *testing.T) {
 	pc.Do("PUBLISH", "c1", "hello")
 	expectPushed(t, c, "PUBLISH c1 hello", redis.Message{"c1", []byte("hello")})
 }
+
+func TestReceiveChan(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SUBSCRIBE":
+			return "*3\r\n$9\r\nsubscribe\r\n$2\r\nc1\r\n:1\r\n" +
+				"*3\r\n$7\r\nmessage\r\n$2\r\nc1\r\n$5\r\nhello\r\n" +
+				"-ERR bad\r\n"
+		case "PING":
+			return "*2\r\n$4\r\npong\r\n$" + fmt.Sprint(len(args[1])) + "\r\n" + args[1] + "\r\n"
+		}
+		return ""
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	psc := redis.NewPubSubConn(c)
+	notifications, errs := psc.ReceiveChanSize(1)
+
+	psc.Subscribe("c1")
+	want := []interface{}{redis.Subscription{"subscribe", "c1", 1}, redis.Message{"c1", []byte("hello")}}
+	for _, w := range want {
+		if n := <-notifications; !reflect.DeepEqual(n, w) {
+			t.Errorf("received %v, want %v", n, w)
+		}
+	}
+	if err := <-errs; err == nil || err.Error() != "ERR bad" {
+		t.Errorf("received error %v, want ERR bad", err)
+	}
+	psc.Ping("hi")
+	if n := <-notifications; n != (redis.Pong{"hi"}) {
+		t.Errorf("received %v, want pong", n)
+	}
+
+	// The goroutine exits when the connection is closed, even if the
+	// application stopped receiving.
+	psc.Ping("unread")
+	psc.Ping("unread")
+	time.Sleep(10 * time.Millisecond)
+	psc.Close()
+	timeout := time.After(time.Second)
+	for notifications != nil || errs != nil {
+		select {
+		case _, ok := <-notifications:
+			if !ok {
+				notifications = nil
+			}
+		case _, ok := <-errs:
+			if !ok {
+				errs = nil
+			}
+		case <-timeout:
+			t.Fatal("channels not closed after Close")
+		}
+	}
+}
+
+func TestPubSubReceiveWithTimeout(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		if args[0] == "SUBSCRIBE" {
+			return "*3\r\n$9\r\nsubscribe\r\n$5\r\nquiet\r\n:1\r\n"
+		}
+		return "*2\r\n$4\r\npong\r\n$0\r\n\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+	psc := redis.NewPubSubConn(c)
+
+	psc.Subscribe("quiet")
+	if n := psc.ReceiveWithTimeout(time.Second); n != (redis.Subscription{"subscribe", "quiet", 1}) {
+		t.Fatalf("received %v, want subscription", n)
+	}
+	for i := 0; i < 2; i++ {
+		n := psc.ReceiveWithTimeout(10 * time.Millisecond)
+		err, ok := n.(interface {
+			Timeout() bool
+		})
+		if !ok || !err.Timeout() {
+			t.Fatalf("received %v, want timeout error", n)
+		}
+		if c.Err() != nil {
+			t.Fatalf("c.Err() = %v after timeout, want nil", c.Err())
+		}
+	}
+	psc.Ping("")
+	if n := psc.ReceiveWithTimeout(time.Second); n != (redis.Pong{""}) {
+		t.Fatalf("received %v after timeout, want pong", n)
+	}
+}
+
+func TestPubSubPing(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "SUBSCRIBE":
+			return "*3\r\n$9\r\nsubscribe\r\n$4\r\npong\r\n:1\r\n" +
+				"*3\r\n$7\r\nmessage\r\n$4\r\npong\r\n$4\r\npong\r\n"
+		case "PING":
+			if len(args) == 1 {
+				return "+PONG\r\n"
+			}
+			return "*2\r\n$4\r\npong\r\n$" + fmt.Sprint(len(args[1])) + "\r\n" + args[1] + "\r\n"
+		}
+		return ""
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+	psc := redis.NewPubSubConn(c)
+
+	c.Send("PING")
+	c.Flush()
+	expectPushed(t, psc, "PING without subscriptions", redis.Pong{})
+
+	psc.Subscribe("pong")
+	psc.Ping("pong")
+	expectPushed(t, psc, "Subscribe(pong)", redis.Subscription{"subscribe", "pong", 1})
+	expectPushed(t, psc, "message on pong", redis.Message{"pong", []byte("pong")})
+	expectPushed(t, psc, "Ping(pong)", redis.Pong{"pong"})
+}
+
+func TestPubSubTracking(t *testing.T) {
+	// The fake server keeps the subscriptions of a single client.
+	var mu sync.Mutex
+	subscribed := map[string]map[string]bool{"subscribe": {}, "psubscribe": {}}
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		kind := strings.ToLower(args[0])
+		set := subscribed["subscribe"]
+		if strings.HasPrefix(kind, "p") {
+			set = subscribed["psubscribe"]
+		}
+		names := args[1:]
+		if len(names) == 0 {
+			for name := range set {
+				names = append(names, name)
+			}
+		}
+		var reply string
+		for _, name := range names {
+			if strings.Contains(kind, "un") {
+				delete(set, name)
+			} else {
+				set[name] = true
+			}
+			count := len(subscribed["subscribe"]) + len(subscribed["psubscribe"])
+			reply += fmt.Sprintf("*3\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n:%d\r\n", len(kind), kind, len(name), name, count)
+		}
+		return reply
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+	psc := redis.NewPubSubConn(c)
+
+	psc.Subscribe("c2", "c1", "c3")
+	psc.PSubscribe("p*")
+	psc.Unsubscribe("c3")
+	for i := 0; i < 5; i++ {
+		if _, ok := psc.Receive().(redis.Subscription); !ok {
+			t.Fatal("Receive did not return subscription")
+		}
+	}
+	if got := psc.Channels(); !reflect.DeepEqual(got, []string{"c1", "c2"}) {
+		t.Errorf("Channels() = %v, want [c1 c2]", got)
+	}
+	if got := psc.Patterns(); !reflect.DeepEqual(got, []string{"p*"}) {
+		t.Errorf("Patterns() = %v, want [p*]", got)
+	}
+	if got := (redis.PubSubConn{Conn: c}).Channels(); got != nil {
+		t.Errorf("Channels() without NewPubSubConn = %v, want nil", got)
+	}
+
+	psc.UnsubscribeAll()
+	for i := 0; i < 3; i++ {
+		if _, ok := psc.Receive().(redis.Subscription); !ok {
+			t.Fatal("Receive did not return subscription")
+		}
+	}
+	if len(psc.Channels()) != 0 || len(psc.Patterns()) != 0 {
+		t.Errorf("Channels() = %v, Patterns() = %v after UnsubscribeAll, want none", psc.Channels(), psc.Patterns())
+	}
+}
+
+type uncomparableConn struct {
+	redis.Conn
+	_ []int
+}
+
+func TestSubscribeAndWait(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		// Messages on an existing subscription arrive before and between
+		// the confirmations.
+		return "*3\r\n$7\r\nmessage\r\n$3\r\nold\r\n$1\r\n1\r\n" +
+			"*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:2\r\n" +
+			"*3\r\n$7\r\nmessage\r\n$3\r\nold\r\n$1\r\n2\r\n" +
+			"*3\r\n$9\r\nsubscribe\r\n$1\r\nb\r\n:3\r\n" +
+			"*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$1\r\n3\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	// The connection does not need to be comparable.
+	psc := redis.NewPubSubConn(uncomparableConn{Conn: c})
+	if err := psc.SubscribeAndWait("a", []byte("b")); err != nil {
+		t.Fatalf("SubscribeAndWait returned %v", err)
+	}
+	for _, want := range []redis.Message{{"old", []byte("1")}, {"old", []byte("2")}, {"a", []byte("3")}} {
+		if m := psc.Receive(); !reflect.DeepEqual(m, want) {
+			t.Errorf("Receive() = %v, want %v", m, want)
+		}
+	}
+
+	if err := (redis.PubSubConn{Conn: c}).SubscribeAndWait("a"); err == nil {
+		t.Error("SubscribeAndWait without NewPubSubConn returned nil error")
+	}
+}
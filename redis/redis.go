@@ -14,11 +14,40 @@
 
 package redis
 
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
 // Error represents an error returned in a command reply.
 type Error string
 
 func (err Error) Error() string { return string(err) }
 
+// Code returns the first word of the error, which by convention is an error
+// code such as "ERR", "WRONGTYPE", "MOVED" or "NOSCRIPT". Use the code to
+// decide how to handle an error without matching the full message.
+func (err Error) Code() string {
+	if i := strings.IndexByte(string(err), ' '); i >= 0 {
+		return string(err[:i])
+	}
+	return string(err)
+}
+
+// Message returns the error with the code removed.
+func (err Error) Message() string {
+	if i := strings.IndexByte(string(err), ' '); i >= 0 {
+		return string(err[i+1:])
+	}
+	return ""
+}
+
+// Map represents a RESP3 map reply. Keys of type bulk, status and integer
+// are converted to strings.
+type Map map[string]interface{}
+
 // Conn represents a connection to a Redis server.
 type Conn interface {
 	// Close closes the connection.
@@ -42,3 +71,77 @@ type Conn interface {
 	// Receive receives a single reply from the Redis server
 	Receive() (reply interface{}, err error)
 }
+
+// ConnWithContext is an optional interface that allows the caller to control
+// the command's life with a context.
+type ConnWithContext interface {
+	Conn
+
+	// DoContext sends a command to the server and returns the received reply.
+	// If the context is done before the reply is received, then DoContext
+	// returns the context's error and the connection is broken.
+	DoContext(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+var errContextNotSupported = errors.New("redigo: connection does not support ConnWithContext")
+
+// DoContext sends a command to the server and returns the received reply
+// using the context to control the command's life. An error is returned if
+// the connection does not implement ConnWithContext.
+func DoContext(c Conn, ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	cwc, ok := c.(ConnWithContext)
+	if !ok {
+		return nil, errContextNotSupported
+	}
+	return cwc.DoContext(ctx, commandName, args...)
+}
+
+// ConnWithTimeout is an optional interface that allows the caller to override
+// a connection's default read timeout. This interface is useful for executing
+// the BLPOP, BRPOP, BRPOPLPUSH, XREAD and other commands that block at the
+// server.
+//
+// A connection's default read timeout is set with the DialReadTimeout option.
+// Applications should rely on the default timeout for commands that do not
+// block at the server.
+type ConnWithTimeout interface {
+	Conn
+
+	// DoWithTimeout sends a command to the server and returns the received
+	// reply. The timeout overrides the read timeout set when dialing the
+	// connection. A zero timeout means no read deadline. If the timeout
+	// expires, then the connection is broken.
+	DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (reply interface{}, err error)
+
+	// ReceiveWithTimeout receives a single reply from the Redis server. The
+	// timeout overrides the read timeout set when dialing the connection. A
+	// zero timeout means no read deadline. If the timeout expires, then the
+	// connection is broken unless no replies to sent commands are pending
+	// and nothing was read, as is the case for a connection in subscribe
+	// mode waiting for messages.
+	ReceiveWithTimeout(timeout time.Duration) (reply interface{}, err error)
+}
+
+var errTimeoutNotSupported = errors.New("redigo: connection does not support ConnWithTimeout")
+
+// DoWithTimeout executes a Redis command with the specified read timeout. If
+// the connection does not satisfy the ConnWithTimeout interface, then an error
+// is returned.
+func DoWithTimeout(c Conn, timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	cwt, ok := c.(ConnWithTimeout)
+	if !ok {
+		return nil, errTimeoutNotSupported
+	}
+	return cwt.DoWithTimeout(timeout, cmd, args...)
+}
+
+// ReceiveWithTimeout receives a reply with the specified read timeout. If the
+// connection does not satisfy the ConnWithTimeout interface, then an error is
+// returned.
+func ReceiveWithTimeout(c Conn, timeout time.Duration) (interface{}, error) {
+	cwt, ok := c.(ConnWithTimeout)
+	if !ok {
+		return nil, errTimeoutNotSupported
+	}
+	return cwt.ReceiveWithTimeout(timeout)
+}
@@ -0,0 +1,239 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxBackoff = 30 * time.Second
+	initialBackoff    = 100 * time.Millisecond
+)
+
+// Reconnect is sent on the channel returned by ReliablePubSub.Start after
+// the subscriber reconnected and subscribed again. Messages published while
+// the subscriber was disconnected are lost.
+type Reconnect struct {
+	// The error that broke the previous connection.
+	Err error
+}
+
+// ReliablePubSub is a subscriber that reconnects and subscribes again when
+// the connection is broken. The notifications received by the underlying
+// PubSubConn are sent on a single channel that survives reconnects.
+//
+// The application sets the fields of a ReliablePubSub before calling Start.
+type ReliablePubSub struct {
+	// Dial is an application supplied function for creating connections.
+	Dial func() (Conn, error)
+
+	// Channels and patterns to subscribe to.
+	Channels []string
+	Patterns []string
+
+	// Maximum time to wait between failed attempts to reconnect. The wait
+	// doubles after each failed attempt, starting at 100 milliseconds or
+	// MaxBackoff if that is less. A connection that breaks before a message
+	// is received on it counts as a failed attempt. If the value is zero,
+	// then the maximum is 30 seconds.
+	MaxBackoff time.Duration
+
+	// Buffer size of the channel returned by Start.
+	BufferSize int
+
+	once sync.Once
+	done chan struct{}
+	exit chan struct{}
+
+	// mu protects fields defined below.
+	mu   sync.Mutex
+	conn Conn
+}
+
+var errReliablePubSubStarted = errors.New("redigo: ReliablePubSub already started")
+
+// Start connects and subscribes in a goroutine. The returned channel receives
+// the Subscription, Message, PMessage and Pong values from the connection and
+// a Reconnect value after each reconnect. The channel is closed after Close
+// is called.
+func (r *ReliablePubSub) Start() (<-chan interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done != nil {
+		return nil, errReliablePubSubStarted
+	}
+	r.done = make(chan struct{})
+	r.exit = make(chan struct{})
+	out := make(chan interface{}, r.BufferSize)
+	go r.run(out)
+	return out, nil
+}
+
+// Close closes the connection and stops the subscriber.
+func (r *ReliablePubSub) Close() error {
+	r.mu.Lock()
+	done, exit, conn := r.done, r.exit, r.conn
+	r.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	r.once.Do(func() { close(done) })
+	if conn != nil {
+		conn.Close()
+	}
+	<-exit
+	return nil
+}
+
+func (r *ReliablePubSub) run(out chan interface{}) {
+	defer close(r.exit)
+	defer close(out)
+
+	maxBackoff := r.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	backoff := time.Duration(0)
+	grow := func() {
+		if backoff == 0 {
+			backoff = initialBackoff
+		} else {
+			backoff *= 2
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	connected := false
+	var lastErr error
+
+	for {
+		if backoff > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-r.done:
+				return
+			}
+		}
+
+		c, err := r.subscribe()
+		if r.stopped() {
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if err != nil {
+			if lastErr == nil {
+				lastErr = err
+			}
+			grow()
+			continue
+		}
+
+		ok := true
+		if connected {
+			ok = r.send(out, Reconnect{Err: lastErr})
+		}
+		connected = true
+		lastErr = nil
+
+		// The backoff is reset only after a message shows that the
+		// connection is healthy, so that a server that accepts the
+		// subscription and then drops the connection is not redialed in a
+		// tight loop.
+		healthy := false
+		psc := NewPubSubConn(c)
+		for ok {
+			n := psc.Receive()
+			if err, isErr := n.(error); isErr && c.Err() != nil {
+				lastErr = err
+				break
+			}
+			switch n.(type) {
+			case Message, PMessage:
+				healthy = true
+			}
+			ok = r.send(out, n)
+		}
+		psc.Close()
+		if healthy {
+			backoff = 0
+		} else {
+			grow()
+		}
+		r.mu.Lock()
+		r.conn = nil
+		r.mu.Unlock()
+		if r.stopped() {
+			return
+		}
+	}
+}
+
+// subscribe dials a connection and sends the subscribe commands.
+func (r *ReliablePubSub) subscribe() (Conn, error) {
+	c, err := r.Dial()
+	if err != nil {
+		return nil, err
+	}
+	// Store the connection so that Close can unblock Receive. Close may
+	// run before the connection is stored, so the caller checks whether the
+	// subscriber is stopped after subscribe returns.
+	r.mu.Lock()
+	r.conn = c
+	r.mu.Unlock()
+
+	if len(r.Channels) > 0 {
+		c.Send("SUBSCRIBE", stringsToArgs(r.Channels)...)
+	}
+	if len(r.Patterns) > 0 {
+		c.Send("PSUBSCRIBE", stringsToArgs(r.Patterns)...)
+	}
+	if err := c.Flush(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *ReliablePubSub) send(out chan interface{}, n interface{}) bool {
+	select {
+	case out <- n:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+func (r *ReliablePubSub) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func stringsToArgs(s []string) []interface{} {
+	args := make([]interface{}, len(s))
+	for i := range s {
+		args[i] = s[i]
+	}
+	return args
+}
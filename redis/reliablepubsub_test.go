@@ -0,0 +1,138 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReliablePubSub(t *testing.T) {
+	s := NewFakeServer(t, func(args []string) string {
+		kind := strings.ToLower(args[0])
+		if kind != "subscribe" && kind != "psubscribe" {
+			return ""
+		}
+		var reply string
+		for i, name := range args[1:] {
+			reply += fmt.Sprintf("*3\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n:%d\r\n", len(kind), kind, len(name), name, i+1)
+		}
+		return reply
+	})
+	defer s.Close()
+
+	var mu sync.Mutex
+	dials, failures := 0, 0
+	r := &ReliablePubSub{
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			dials++
+			if failures > 0 {
+				failures--
+				return nil, errors.New("dial failed")
+			}
+			return Dial("tcp", s.Addr())
+		},
+		Channels:   []string{"c1"},
+		Patterns:   []string{"p*"},
+		MaxBackoff: 10 * time.Millisecond,
+	}
+	notifications, err := r.Start()
+	if err != nil {
+		t.Fatalf("Start returned %v", err)
+	}
+	if _, err := r.Start(); err == nil {
+		t.Fatal("second Start returned nil error")
+	}
+
+	receive := func() interface{} {
+		select {
+		case n := <-notifications:
+			return n
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for notification")
+		}
+		return nil
+	}
+	expectSubscriptions := func() {
+		for _, want := range []Subscription{{"subscribe", "c1", 1}, {"psubscribe", "p*", 1}} {
+			if n := receive(); n != want {
+				t.Fatalf("received %v, want %v", n, want)
+			}
+		}
+	}
+
+	expectSubscriptions()
+
+	mu.Lock()
+	failures = 3
+	mu.Unlock()
+	s.DropConns()
+
+	if n, ok := receive().(Reconnect); !ok || n.Err == nil {
+		t.Fatalf("received %v, want reconnect with error", n)
+	}
+	expectSubscriptions()
+	mu.Lock()
+	if dials != 5 {
+		t.Errorf("dials = %d, want 5", dials)
+	}
+	mu.Unlock()
+
+	r.Close()
+	for range notifications {
+	}
+}
+
+func TestReliablePubSubBackoff(t *testing.T) {
+	// The server confirms nothing and breaks the connection with a
+	// malformed reply to SUBSCRIBE.
+	s := NewFakeServer(t, func(args []string) string { return "?\r\n" })
+	defer s.Close()
+
+	var mu sync.Mutex
+	dials := 0
+	r := &ReliablePubSub{
+		Dial: func() (Conn, error) {
+			mu.Lock()
+			dials++
+			mu.Unlock()
+			return Dial("tcp", s.Addr())
+		},
+		Channels:   []string{"c1"},
+		MaxBackoff: 40 * time.Millisecond,
+	}
+	notifications, err := r.Start()
+	if err != nil {
+		t.Fatalf("Start returned %v", err)
+	}
+	go func() {
+		for range notifications {
+		}
+	}()
+	time.Sleep(300 * time.Millisecond)
+	r.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if dials < 2 || dials > 10 {
+		t.Errorf("dials = %d in 300ms, want reconnects separated by the backoff", dials)
+	}
+}
@@ -22,6 +22,9 @@ import (
 
 var ErrNil = errors.New("redigo: nil returned")
 
+// ErrNoSuchKey indicates that the key given to a command does not exist.
+var ErrNoSuchKey = errors.New("redigo: no such key")
+
 // Int is a helper that converts a command reply to an integer. If err is not
 // equal to nil, then Int returns 0, err. Otherwise, Int converts the
 // reply to an int as follows:
@@ -80,12 +83,43 @@ func Int64(reply interface{}, err error) (int64, error) {
 	return 0, fmt.Errorf("redigo: unexpected type for Int64, got type %T", reply)
 }
 
+// Uint64 is a helper that converts a command reply to 64 bit unsigned
+// integer. If err is not equal to nil, then Uint64 returns 0, err. Otherwise,
+// Uint64 converts the reply to an uint64 as follows:
+//
+//  Reply type    Result
+//  integer       reply, nil (error if negative)
+//  bulk          parsed reply, nil
+//  nil           0, ErrNil
+//  other         0, error
+func Uint64(reply interface{}, err error) (uint64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch reply := reply.(type) {
+	case int64:
+		if reply < 0 {
+			return 0, fmt.Errorf("redigo: unexpected negative value %d for Uint64", reply)
+		}
+		return uint64(reply), nil
+	case []byte:
+		n, err := strconv.ParseUint(string(reply), 10, 64)
+		return n, err
+	case nil:
+		return 0, ErrNil
+	case Error:
+		return 0, reply
+	}
+	return 0, fmt.Errorf("redigo: unexpected type for Uint64, got type %T", reply)
+}
+
 // Float64 is a helper that converts a command reply to 64 bit float. If err is
 // not equal to nil, then Float64 returns 0, err. Otherwise, Float64 converts
 // the reply to an int as follows:
 //
 //  Reply type    Result
 //  bulk          parsed reply, nil
+//  double        reply, nil
 //  nil           0, ErrNil
 //  other         0, error
 func Float64(reply interface{}, err error) (float64, error) {
@@ -93,6 +127,8 @@ func Float64(reply interface{}, err error) (float64, error) {
 		return 0, err
 	}
 	switch reply := reply.(type) {
+	case float64:
+		return reply, nil
 	case []byte:
 		n, err := strconv.ParseFloat(string(reply), 64)
 		return n, err
@@ -163,6 +199,7 @@ func Bytes(reply interface{}, err error) ([]byte, error) {
 //  Reply type      Result
 //  integer         value != 0, nil
 //  bulk            strconv.ParseBool(reply)
+//  boolean         reply, nil
 //  nil             false, ErrNil
 //  other           false, error
 func Bool(reply interface{}, err error) (bool, error) {
@@ -170,6 +207,8 @@ func Bool(reply interface{}, err error) (bool, error) {
 		return false, err
 	}
 	switch reply := reply.(type) {
+	case bool:
+		return reply, nil
 	case int64:
 		return reply != 0, nil
 	case []byte:
@@ -191,8 +230,13 @@ func MultiBulk(reply interface{}, err error) ([]interface{}, error) { return Val
 //
 //  Reply type      Result
 //  multi-bulk      reply, nil
+//  map             alternating keys and values, nil
 //  nil             nil, ErrNil
 //  other           nil, error
+//
+// The keys of a map are converted to bulk values so that helpers such as
+// Float64Map and ScanStruct work with RESP2 and RESP3 replies alike. The
+// order of the pairs is not specified.
 func Values(reply interface{}, err error) ([]interface{}, error) {
 	if err != nil {
 		return nil, err
@@ -200,6 +244,12 @@ func Values(reply interface{}, err error) ([]interface{}, error) {
 	switch reply := reply.(type) {
 	case []interface{}:
 		return reply, nil
+	case Map:
+		values := make([]interface{}, 0, 2*len(reply))
+		for k, v := range reply {
+			values = append(values, []byte(k), v)
+		}
+		return values, nil
 	case nil:
 		return nil, ErrNil
 	case Error:
@@ -236,3 +286,313 @@ func Strings(reply interface{}, err error) ([]string, error) {
 	}
 	return nil, fmt.Errorf("redigo: unexpected type for Strings, got type %T", reply)
 }
+
+// ByteSlices is a helper that converts a multi-bulk command reply to a
+// [][]byte. If err is not equal to nil, then ByteSlices returns nil, err. Nil
+// elements are returned as nil. If one of the multi-bulk items is not a bulk
+// value or nil, then ByteSlices returns an error.
+//
+// The elements are the bulk values of the reply and are not copied. The
+// connection allocates a new slice for each bulk value it reads, so the
+// elements can be retained and modified by the application.
+func ByteSlices(reply interface{}, err error) ([][]byte, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]byte, len(values))
+	for i := range values {
+		if values[i] == nil {
+			continue
+		}
+		p, ok := values[i].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected element type for ByteSlices, got type %T", values[i])
+		}
+		result[i] = p
+	}
+	return result, nil
+}
+
+// Bools is a helper that converts a multi-bulk command reply to a []bool.
+// The SMISMEMBER command returns replies in this format. If err is not equal
+// to nil, then Bools returns nil, err. Nil elements are converted to false,
+// status elements are true if they are "OK" and other elements are converted
+// as in Bool. An empty multi-bulk reply is converted to an empty slice.
+func Bools(reply interface{}, err error) ([]bool, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]bool, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			if v == "OK" {
+				result[i] = true
+				continue
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("redigo: Bools cannot parse element %d: %v", i, err)
+			}
+			result[i] = b
+		case int64, bool, []byte:
+			b, err := Bool(v, nil)
+			if err != nil {
+				return nil, fmt.Errorf("redigo: Bools cannot parse element %d: %v", i, err)
+			}
+			result[i] = b
+		default:
+			return nil, fmt.Errorf("redigo: unexpected element type for Bools, got type %T", v)
+		}
+	}
+	return result, nil
+}
+
+// Float64Map is a helper that converts a multi-bulk command reply with
+// alternating keys and values to a map[string]float64. The HGETALL command
+// returns replies in this format. If err is not equal to nil, then
+// Float64Map returns nil, err. The values are converted with
+// strconv.ParseFloat.
+func Float64Map(result interface{}, err error) (map[string]float64, error) {
+	values, err := Values(result, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: Float64Map expects even number of values result")
+	}
+	m := make(map[string]float64, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected key type for Float64Map, got type %T", values[i])
+		}
+		value, ok := values[i+1].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected value type for Float64Map key %q, got type %T", key, values[i+1])
+		}
+		f, err := strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("redigo: Float64Map cannot parse value of key %q: %v", key, err)
+		}
+		m[string(key)] = f
+	}
+	return m, nil
+}
+
+// Int64Map is a helper that converts a multi-bulk command reply with
+// alternating keys and values to a map[string]int64. The HGETALL command
+// returns replies in this format. If err is not equal to nil, then Int64Map
+// returns nil, err. The values are converted with strconv.ParseInt.
+func Int64Map(result interface{}, err error) (map[string]int64, error) {
+	values, err := Values(result, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: Int64Map expects even number of values result")
+	}
+	m := make(map[string]int64, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected key type for Int64Map, got type %T", values[i])
+		}
+		value, ok := values[i+1].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected value type for Int64Map key %q, got type %T", key, values[i+1])
+		}
+		n, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redigo: Int64Map cannot parse value of key %q: %v", key, err)
+		}
+		m[string(key)] = n
+	}
+	return m, nil
+}
+
+// ZScores is a helper that converts a command reply with members and scores
+// to a slice of ZMember. The ZRANGE command with the WITHSCORES option
+// returns replies in this format, either as a multi-bulk reply alternating
+// members and scores or, with RESP3, as pairs of a member and a score. The
+// members are strings and the scores are parsed with strconv.ParseFloat,
+// which accepts "inf", "-inf" and "nan". If err is not equal to nil, then
+// ZScores returns nil, err.
+func ZScores(reply interface{}, err error) ([]ZMember, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) > 0 {
+		if _, ok := values[0].([]interface{}); ok {
+			// RESP3 reply with a pair for each member.
+			var flat []interface{}
+			for _, v := range values {
+				pair, ok := v.([]interface{})
+				if !ok || len(pair) != 2 {
+					return nil, errors.New("redigo: ZScores expects pairs of member and score")
+				}
+				flat = append(flat, pair...)
+			}
+			values = flat
+		}
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: ZScores expects even number of values result")
+	}
+	members := make([]ZMember, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		member, err := String(values[i], nil)
+		if err != nil {
+			return nil, fmt.Errorf("redigo: unexpected member type for ZScores, got type %T", values[i])
+		}
+		score, err := Float64(values[i+1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("redigo: ZScores cannot parse score of member %q: %v", member, err)
+		}
+		members = append(members, ZMember{Score: score, Member: member})
+	}
+	return members, nil
+}
+
+// StreamEntry is an entry of a Redis stream.
+type StreamEntry struct {
+	ID     string
+	Fields map[string]string
+}
+
+// StreamEntries is a helper that converts the reply of the XRANGE and
+// XREVRANGE commands to a []StreamEntry. If err is not equal to nil, then
+// StreamEntries returns nil, err. The Fields of an entry that was deleted
+// while it was pending are nil.
+func StreamEntries(reply interface{}, err error) ([]StreamEntry, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]StreamEntry, len(values))
+	for i, v := range values {
+		fields, err := Values(v, nil)
+		if err != nil || len(fields) != 2 {
+			return nil, fmt.Errorf("redigo: unexpected stream entry %d for StreamEntries, got type %T", i, v)
+		}
+		id, ok := fields[0].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected ID type for StreamEntries, got type %T", fields[0])
+		}
+		entries[i].ID = string(id)
+		if fields[1] == nil {
+			continue
+		}
+		kvs, err := Strings(fields[1], nil)
+		if err != nil {
+			return nil, fmt.Errorf("redigo: StreamEntries cannot parse fields of entry %s: %v", id, err)
+		}
+		if len(kvs)%2 != 0 {
+			return nil, fmt.Errorf("redigo: StreamEntries expects even number of fields in entry %s", id)
+		}
+		entries[i].Fields = make(map[string]string, len(kvs)/2)
+		for j := 0; j < len(kvs); j += 2 {
+			entries[i].Fields[kvs[j]] = kvs[j+1]
+		}
+	}
+	return entries, nil
+}
+
+// StreamsRead is a helper that converts the reply of the XREAD and
+// XREADGROUP commands to a map from stream name to the entries read from the
+// stream. If err is not equal to nil, then StreamsRead returns nil, err. The
+// entries are converted as in StreamEntries. The commands return a nil reply
+// when they time out, in which case StreamsRead returns nil, ErrNil.
+func StreamsRead(reply interface{}, err error) (map[string][]StreamEntry, error) {
+	if m, ok := reply.(Map); ok && err == nil {
+		streams := make(map[string][]StreamEntry, len(m))
+		for name, v := range m {
+			entries, err := StreamEntries(v, nil)
+			if err != nil {
+				return nil, err
+			}
+			streams[name] = entries
+		}
+		return streams, nil
+	}
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	streams := make(map[string][]StreamEntry, len(values))
+	for _, v := range values {
+		stream, err := Values(v, nil)
+		if err != nil || len(stream) != 2 {
+			return nil, fmt.Errorf("redigo: unexpected stream for StreamsRead, got type %T", v)
+		}
+		name, ok := stream[0].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected stream name type for StreamsRead, got type %T", stream[0])
+		}
+		entries, err := StreamEntries(stream[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		streams[string(name)] = entries
+	}
+	return streams, nil
+}
+
+// Uint64s is a helper that converts a multi-bulk command reply to a
+// []uint64. If err is not equal to nil, then Uint64s returns nil, err. Nil
+// elements are converted to 0. Elements are converted as in Uint64.
+func Uint64s(reply interface{}, err error) ([]uint64, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]uint64, len(values))
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		if result[i], err = Uint64(v, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Positions is a helper that converts a multi-bulk command reply of member
+// positions to a []*[2]float64. The GEOPOS command returns replies in this
+// format. Each entry is a pointer to a {longitude, latitude} pair or nil if
+// the member does not exist.
+func Positions(reply interface{}, err error) ([]*[2]float64, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]*[2]float64, len(values))
+	for i := range values {
+		if values[i] == nil {
+			continue
+		}
+		p, ok := values[i].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("redigo: unexpected element type for Positions, got type %T", values[i])
+		}
+		if len(p) != 2 {
+			return nil, fmt.Errorf("redigo: unexpected number of values for a member position, got %d", len(p))
+		}
+		lng, err := Float64(p[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		lat, err := Float64(p[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		positions[i] = &[2]float64{lng, lat}
+	}
+	return positions, nil
+}
@@ -16,7 +16,9 @@ package redis_test
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/garyburd/redigo/redis"
@@ -66,6 +68,284 @@ var replyTests = []struct {
 		ve(redis.Float64(nil, nil)),
 		ve(float64(0.0), redis.ErrNil),
 	},
+	{
+		"float64(double)",
+		ve(redis.Float64(2.5, nil)),
+		ve(float64(2.5), nil),
+	},
+	{
+		"bool(true)",
+		ve(redis.Bool(true, nil)),
+		ve(true, nil),
+	},
+	{
+		"uint64(1)",
+		ve(redis.Uint64(int64(1), nil)),
+		ve(uint64(1), nil),
+	},
+	{
+		"uint64(18446744073709551615)",
+		ve(redis.Uint64([]byte("18446744073709551615"), nil)),
+		ve(uint64(18446744073709551615), nil),
+	},
+	{
+		"uint64(nil)",
+		ve(redis.Uint64(nil, nil)),
+		ve(uint64(0), redis.ErrNil),
+	},
+	{
+		"uint64s([1, 18446744073709551615, nil])",
+		ve(redis.Uint64s([]interface{}{int64(1), []byte("18446744073709551615"), nil}, nil)),
+		ve([]uint64{1, 18446744073709551615, 0}, nil),
+	},
+	{
+		"uint64s(nil)",
+		ve(redis.Uint64s(nil, nil)),
+		ve([]uint64(nil), redis.ErrNil),
+	},
+	{
+		"positions([[1, 2], nil, [3, 4]])",
+		ve(redis.Positions([]interface{}{[]interface{}{[]byte("1"), []byte("2")}, nil, []interface{}{[]byte("3"), []byte("4")}}, nil)),
+		ve([]*[2]float64{{1.0, 2.0}, nil, {3.0, 4.0}}, nil),
+	},
+	{
+		"positions(nil)",
+		ve(redis.Positions(nil, nil)),
+		ve([]*[2]float64(nil), redis.ErrNil),
+	},
+	{
+		"float64Map([k1, 1.5, k2, -2])",
+		ve(redis.Float64Map([]interface{}{[]byte("k1"), []byte("1.5"), []byte("k2"), []byte("-2")}, nil)),
+		ve(map[string]float64{"k1": 1.5, "k2": -2}, nil),
+	},
+	{
+		"float64Map(nil)",
+		ve(redis.Float64Map(nil, nil)),
+		ve(map[string]float64(nil), redis.ErrNil),
+	},
+	{
+		"int64Map([k1, 1, k2, 9223372036854775807])",
+		ve(redis.Int64Map([]interface{}{[]byte("k1"), []byte("1"), []byte("k2"), []byte("9223372036854775807")}, nil)),
+		ve(map[string]int64{"k1": 1, "k2": 9223372036854775807}, nil),
+	},
+	{
+		"byteSlices([v1, nil, v2])",
+		ve(redis.ByteSlices([]interface{}{[]byte("v1"), nil, []byte("v2")}, nil)),
+		ve([][]byte{[]byte("v1"), nil, []byte("v2")}, nil),
+	},
+	{
+		"byteSlices(nil)",
+		ve(redis.ByteSlices(nil, nil)),
+		ve([][]byte(nil), redis.ErrNil),
+	},
+	{
+		"bools([1, 0, OK, 2])",
+		ve(redis.Bools([]interface{}{int64(1), int64(0), "OK", int64(2)}, nil)),
+		ve([]bool{true, false, true, true}, nil),
+	},
+	{
+		"bools([])",
+		ve(redis.Bools([]interface{}{}, nil)),
+		ve([]bool{}, nil),
+	},
+	{
+		"bools(nil)",
+		ve(redis.Bools(nil, nil)),
+		ve([]bool(nil), redis.ErrNil),
+	},
+	{
+		"int64Map(nil)",
+		ve(redis.Int64Map(nil, nil)),
+		ve(map[string]int64(nil), redis.ErrNil),
+	},
+}
+
+func TestUint64Errors(t *testing.T) {
+	for _, reply := range []interface{}{int64(-1), []byte("-1"), []byte("18446744073709551616"), "1"} {
+		if _, err := redis.Uint64(reply, nil); err == nil {
+			t.Errorf("Uint64(%v) returned nil error", reply)
+		}
+	}
+	if _, err := redis.Uint64s([]interface{}{int64(1), int64(-1)}, nil); err == nil {
+		t.Error("Uint64s with negative element returned nil error")
+	}
+}
+
+func TestPositionsErrors(t *testing.T) {
+	for _, reply := range []interface{}{
+		[]interface{}{[]byte("1")},
+		[]interface{}{[]interface{}{[]byte("1")}},
+		[]interface{}{[]interface{}{[]byte("1"), []byte("x")}},
+		[]interface{}{[]interface{}{[]byte("1"), []byte("2"), []byte("3")}},
+	} {
+		if _, err := redis.Positions(reply, nil); err == nil {
+			t.Errorf("Positions(%v) returned nil error", reply)
+		}
+	}
+}
+
+func TestFloat64MapErrors(t *testing.T) {
+	for _, reply := range []interface{}{
+		[]interface{}{[]byte("k1")},
+		[]interface{}{int64(1), []byte("1")},
+		[]interface{}{[]byte("k1"), []byte("1"), []byte("k2"), []byte("x")},
+		[]interface{}{[]byte("k1"), nil},
+	} {
+		if _, err := redis.Float64Map(reply, nil); err == nil {
+			t.Errorf("Float64Map(%v) returned nil error", reply)
+		}
+	}
+	_, err := redis.Float64Map([]interface{}{[]byte("k1"), []byte("1"), []byte("k2"), []byte("x")}, nil)
+	if err == nil || !strings.Contains(err.Error(), `"k2"`) {
+		t.Errorf("Float64Map error %v does not identify key", err)
+	}
+}
+
+func TestByteSlicesErrors(t *testing.T) {
+	for _, reply := range []interface{}{
+		[]byte("v1"),
+		[]interface{}{[]byte("v1"), int64(1)},
+	} {
+		if _, err := redis.ByteSlices(reply, nil); err == nil {
+			t.Errorf("ByteSlices(%v) returned nil error", reply)
+		}
+	}
+}
+
+func TestBoolsErrors(t *testing.T) {
+	for _, reply := range []interface{}{
+		int64(1),
+		[]interface{}{int64(1), "QUEUED"},
+		[]interface{}{[]byte("x")},
+		[]interface{}{[]interface{}{}},
+	} {
+		if _, err := redis.Bools(reply, nil); err == nil {
+			t.Errorf("Bools(%v) returned nil error", reply)
+		}
+	}
+}
+
+func streamEntry(id string, kvs ...string) interface{} {
+	if kvs == nil {
+		return []interface{}{[]byte(id), nil}
+	}
+	fields := make([]interface{}, len(kvs))
+	for i, kv := range kvs {
+		fields[i] = []byte(kv)
+	}
+	return []interface{}{[]byte(id), fields}
+}
+
+func TestStreamEntries(t *testing.T) {
+	reply := []interface{}{
+		streamEntry("1-0", "name", "foo", "count", "1"),
+		streamEntry("2-0"),
+	}
+	entries, err := redis.StreamEntries(reply, nil)
+	want := []redis.StreamEntry{
+		{ID: "1-0", Fields: map[string]string{"name": "foo", "count": "1"}},
+		{ID: "2-0"},
+	}
+	if err != nil || !reflect.DeepEqual(entries, want) {
+		t.Errorf("StreamEntries = %v, %v, want %v", entries, err, want)
+	}
+
+	for _, reply := range []interface{}{
+		[]interface{}{[]interface{}{[]byte("1-0")}},
+		[]interface{}{[]interface{}{int64(1), nil}},
+		[]interface{}{streamEntry("1-0", "name")},
+	} {
+		if _, err := redis.StreamEntries(reply, nil); err == nil {
+			t.Errorf("StreamEntries(%v) returned nil error", reply)
+		}
+	}
+}
+
+func TestStreamsRead(t *testing.T) {
+	want := map[string][]redis.StreamEntry{
+		"s1": {{ID: "1-0", Fields: map[string]string{"k": "v"}}},
+		"s2": {},
+	}
+	for _, reply := range []interface{}{
+		[]interface{}{
+			[]interface{}{[]byte("s1"), []interface{}{streamEntry("1-0", "k", "v")}},
+			[]interface{}{[]byte("s2"), []interface{}{}},
+		},
+		redis.Map{
+			"s1": []interface{}{streamEntry("1-0", "k", "v")},
+			"s2": []interface{}{},
+		},
+	} {
+		streams, err := redis.StreamsRead(reply, nil)
+		if err != nil || !reflect.DeepEqual(streams, want) {
+			t.Errorf("StreamsRead(%v) = %v, %v, want %v", reply, streams, err, want)
+		}
+	}
+	if _, err := redis.StreamsRead(nil, nil); err != redis.ErrNil {
+		t.Errorf("StreamsRead(nil) returned %v, want %v", err, redis.ErrNil)
+	}
+	if _, err := redis.StreamsRead([]interface{}{[]interface{}{[]byte("s1")}}, nil); err == nil {
+		t.Error("StreamsRead with missing entries returned nil error")
+	}
+}
+
+func TestInt64MapErrors(t *testing.T) {
+	for _, reply := range []interface{}{
+		[]interface{}{[]byte("k1")},
+		[]interface{}{int64(1), []byte("1")},
+		[]interface{}{[]byte("k1"), []byte("1.5")},
+		[]interface{}{[]byte("k1"), []byte("9223372036854775808")},
+		[]interface{}{[]byte("k1"), nil},
+	} {
+		if _, err := redis.Int64Map(reply, nil); err == nil {
+			t.Errorf("Int64Map(%v) returned nil error", reply)
+		}
+	}
+	_, err := redis.Int64Map([]interface{}{[]byte("k1"), []byte("1"), []byte("k2"), []byte("x")}, nil)
+	if err == nil || !strings.Contains(err.Error(), `"k2"`) {
+		t.Errorf("Int64Map error %v does not identify key", err)
+	}
+}
+
+func TestZScores(t *testing.T) {
+	want := []redis.ZMember{{Score: 1.5, Member: "a"}, {Score: math.Inf(1), Member: "b"}, {Score: math.Inf(-1), Member: "c"}}
+	for _, reply := range []interface{}{
+		[]interface{}{[]byte("a"), []byte("1.5"), []byte("b"), []byte("inf"), []byte("c"), []byte("-inf")},
+		[]interface{}{
+			[]interface{}{[]byte("a"), 1.5},
+			[]interface{}{[]byte("b"), math.Inf(1)},
+			[]interface{}{[]byte("c"), math.Inf(-1)},
+		},
+	} {
+		members, err := redis.ZScores(reply, nil)
+		if err != nil {
+			t.Errorf("ZScores(%v) returned error %v", reply, err)
+			continue
+		}
+		if !reflect.DeepEqual(members, want) {
+			t.Errorf("ZScores(%v) = %v, want %v", reply, members, want)
+		}
+	}
+
+	members, err := redis.ZScores([]interface{}{[]byte("a"), []byte("nan")}, nil)
+	if err != nil || len(members) != 1 || !math.IsNaN(members[0].Score) {
+		t.Errorf("ZScores with nan score = %v, %v", members, err)
+	}
+
+	if members, err := redis.ZScores([]interface{}{}, nil); err != nil || len(members) != 0 {
+		t.Errorf("ZScores of empty reply = %v, %v, want empty", members, err)
+	}
+
+	for _, reply := range []interface{}{
+		[]interface{}{[]byte("a")},
+		[]interface{}{[]byte("a"), []byte("x")},
+		[]interface{}{int64(1), []byte("1")},
+		[]interface{}{[]interface{}{[]byte("a")}},
+	} {
+		if _, err := redis.ZScores(reply, nil); err == nil {
+			t.Errorf("ZScores(%v) returned nil error", reply)
+		}
+	}
 }
 
 func TestReply(t *testing.T) {
@@ -0,0 +1,47 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+)
+
+// Reset clears the state of the connection on the server using the RESET
+// command. The command discards a transaction started with MULTI, unwatches
+// keys, exits subscription and MONITOR modes, selects database 0, turns off
+// client tracking, switches the connection to the RESP2 protocol and
+// authenticates the connection as the default user. RESET requires Redis 6.2
+// or later.
+func Reset(c Conn) error {
+	reply, err := String(c.Do("RESET"))
+	if isUnknownCommand(err) {
+		return fmt.Errorf("redigo: RESET requires Redis 6.2 or later: %v", err)
+	}
+	if err != nil {
+		return err
+	}
+	if reply != "RESET" {
+		return fmt.Errorf("redigo: unexpected reply to RESET: %s", reply)
+	}
+	if pc, ok := c.(*pooledConnection); ok {
+		c = pc.c
+	}
+	if cn, ok := c.(*conn); ok {
+		// Features that require RESP3, such as client side caching, are
+		// not available on the connection anymore.
+		cn.protocol = 2
+	}
+	return nil
+}
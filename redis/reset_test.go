@@ -0,0 +1,76 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestReset(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  string
+	}{
+		{"+RESET\r\n", ""},
+		{"-ERR unknown command 'RESET'\r\n", "requires Redis 6.2"},
+		{"+OK\r\n", "unexpected reply"},
+	}
+	for _, tt := range tests {
+		reply := tt.reply
+		s := redis.NewFakeServer(t, func(args []string) string { return reply })
+		c, err := redis.Dial("tcp", s.Addr())
+		if err != nil {
+			t.Fatalf("redis.Dial returned %v", err)
+		}
+		err = redis.Reset(c)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("Reset with reply %q returned %v", tt.reply, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("Reset with reply %q returned %v, want error containing %q", tt.reply, err, tt.want)
+		}
+		c.Close()
+		s.Close()
+	}
+}
+
+func TestResetProtocol(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%1\r\n+proto\r\n:3\r\n"
+		case "RESET":
+			return "+RESET\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr(), redis.DialProtocol(3))
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+	if err := redis.Reset(c); err != nil {
+		t.Fatalf("Reset returned %v", err)
+	}
+	// RESET switched the connection to RESP2.
+	if _, err := redis.NewTrackingConn(c, redis.TrackingOptions{}); err == nil {
+		t.Error("NewTrackingConn after Reset returned nil error")
+	}
+}
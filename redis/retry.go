@@ -0,0 +1,95 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"time"
+)
+
+const defaultMaxAttempts = 3
+
+var defaultRetryCodes = []string{"LOADING", "CLUSTERDOWN", "TRYAGAIN"}
+
+// RetryPolicy specifies when DoWithRetry retries a command.
+type RetryPolicy struct {
+	// Maximum number of times the command is executed. If zero, then the
+	// command is executed at most 3 times.
+	MaxAttempts int
+
+	// Time to wait before the first retry. The wait doubles after each
+	// retry up to MaxBackoff. If zero, then the first wait is 100
+	// milliseconds or MaxBackoff if that is less.
+	InitialBackoff time.Duration
+
+	// Maximum time to wait between attempts. If zero, then the maximum is
+	// 30 seconds.
+	MaxBackoff time.Duration
+
+	// Codes of the transient errors that are retried, as returned by the
+	// Error Code method. If nil, then the LOADING, CLUSTERDOWN and TRYAGAIN
+	// errors are retried.
+	Codes []string
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	e, ok := err.(Error)
+	if !ok {
+		return false
+	}
+	codes := p.Codes
+	if codes == nil {
+		codes = defaultRetryCodes
+	}
+	code := e.Code()
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// DoWithRetry executes the command and retries it with exponential backoff
+// while the server returns an error with a code in the policy. Other errors,
+// including network errors and errors such as WRONGTYPE, are returned
+// without retrying. If all attempts fail, then the error of the last attempt
+// is returned.
+//
+// Only use DoWithRetry for commands that are safe to execute more than once.
+func DoWithRetry(c Conn, policy RetryPolicy, cmd string, args ...interface{}) (interface{}, error) {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	backoff := policy.InitialBackoff
+	if backoff == 0 {
+		backoff = initialBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		reply, err := c.Do(cmd, args...)
+		if err == nil || attempt >= maxAttempts || !policy.retryable(err) {
+			return reply, err
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redistest"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	policy := redis.RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	loading := redis.Error("LOADING Redis is loading the dataset in memory")
+	tests := []struct {
+		replies  []error
+		policy   redis.RetryPolicy
+		attempts int
+		err      error
+	}{
+		// Transient errors are retried until the command succeeds.
+		{[]error{loading, redis.Error("CLUSTERDOWN The cluster is down"), nil}, policy, 3, nil},
+		// Other errors are not retried.
+		{[]error{redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), nil}, policy, 1,
+			redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")},
+		// The last error is returned after MaxAttempts.
+		{[]error{loading, loading, loading, nil}, policy, 3, loading},
+		// The policy chooses the retryable codes.
+		{[]error{loading, nil}, redis.RetryPolicy{InitialBackoff: time.Millisecond, Codes: []string{"BUSY"}}, 1, loading},
+		{[]error{redis.Error("BUSY Redis is busy running a script"), nil}, redis.RetryPolicy{InitialBackoff: time.Millisecond, Codes: []string{"BUSY"}}, 2, nil},
+	}
+	for i, tt := range tests {
+		c := redistest.NewConn()
+		for _, err := range tt.replies {
+			if err != nil {
+				c.AddReply(nil, err)
+			} else {
+				c.AddReply("OK", nil)
+			}
+		}
+		reply, err := redis.DoWithRetry(c, tt.policy, "GET", "k")
+		if err != tt.err {
+			t.Errorf("%d: DoWithRetry returned error %v, want %v", i, err, tt.err)
+		}
+		if err == nil && reply != "OK" {
+			t.Errorf("%d: DoWithRetry = %v, want OK", i, reply)
+		}
+		if n := len(c.Commands()); n != tt.attempts {
+			t.Errorf("%d: DoWithRetry executed %d commands, want %d", i, n, tt.attempts)
+		}
+	}
+}
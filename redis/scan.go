@@ -15,14 +15,27 @@
 package redis
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+// RedisScanner is implemented by types that decode themselves from a reply
+// value. Scan, ScanStruct and ScanSlice call RedisScan when a destination
+// implements the interface. The src value is a reply value such as []byte,
+// int64, []interface{} or nil.
+type RedisScanner interface {
+	RedisScan(src interface{}) error
+}
+
+var redisScannerType = reflect.TypeOf((*RedisScanner)(nil)).Elem()
+
 func cannotConvert(d reflect.Value, s interface{}) error {
 	return fmt.Errorf("redigo: Scan cannot convert from %s to %s",
 		reflect.TypeOf(s), d.Type())
@@ -91,11 +104,7 @@ func convertAssignValues(d reflect.Value, s []interface{}) (err error) {
 	if d.Type().Kind() != reflect.Slice {
 		return cannotConvert(d, s)
 	}
-	if len(s) > d.Cap() {
-		d.Set(reflect.MakeSlice(d.Type(), len(s), len(s)))
-	} else {
-		d.SetLen(len(s))
-	}
+	ensureLen(d, len(s))
 	for i := 0; i < len(s); i++ {
 		switch s := s[i].(type) {
 		case []byte:
@@ -113,6 +122,10 @@ func convertAssignValues(d reflect.Value, s []interface{}) (err error) {
 }
 
 func convertAssign(d interface{}, s interface{}) (err error) {
+	if scanner, ok := d.(RedisScanner); ok {
+		return scanner.RedisScan(s)
+	}
+
 	// Handle the most common destination types using type switches and
 	// fall back to reflection for all other types.
 	switch s := s.(type) {
@@ -186,9 +199,9 @@ func convertAssign(d interface{}, s interface{}) (err error) {
 
 // Scan copies from the multi-bulk src to the values pointed at by dest.
 //
-// The values pointed at by dest must be an integer, float, boolean, string, or
-// []byte. Scan uses the standard strconv package to convert bulk values to
-// numeric and boolean types.
+// The values pointed at by dest must be an integer, float, boolean, string,
+// []byte or a type implementing RedisScanner. Scan uses the standard strconv
+// package to convert bulk values to numeric and boolean types.
 //
 // If a dest value is nil, then the corresponding src value is skipped.
 //
@@ -212,9 +225,11 @@ func Scan(src []interface{}, dest ...interface{}) ([]interface{}, error) {
 }
 
 type fieldSpec struct {
-	name  string
-	index []int
-	//omitEmpty bool
+	name      string
+	index     []int
+	timeUnit  time.Duration // unit of integer times, zero for RFC 3339
+	omitEmpty bool
+	json      bool
 }
 
 type structSpec struct {
@@ -226,7 +241,10 @@ func (ss *structSpec) fieldSpec(name []byte) *fieldSpec {
 	return ss.m[string(name)]
 }
 
-func compileStructSpec(t reflect.Type, depth map[string]int, index []int, ss *structSpec) {
+// compileStructSpec adds the fields of struct type t to ss. The names of the
+// fields are prefixed with prefix. The parents are the struct types of the
+// prefix fields that t is nested in.
+func compileStructSpec(t reflect.Type, depth map[string]int, index []int, prefix string, parents []reflect.Type, ss *structSpec) {
 	for i := 0; i < t.NumField(); i++ {
 		f := t.Field(i)
 		switch {
@@ -236,12 +254,13 @@ func compileStructSpec(t reflect.Type, depth map[string]int, index []int, ss *st
 			// TODO: Handle pointers. Requires change to decoder and
 			// protection against infinite recursion.
 			if f.Type.Kind() == reflect.Struct {
-				compileStructSpec(f.Type, depth, append(index, i), ss)
+				compileStructSpec(f.Type, depth, append(index, i), prefix, parents, ss)
 			}
 		default:
 			fs := &fieldSpec{name: f.Name}
 			tag := f.Tag.Get("redis")
 			p := strings.Split(tag, ",")
+			nested := false
 			if len(p) > 0 {
 				if p[0] == "-" {
 					continue
@@ -251,13 +270,39 @@ func compileStructSpec(t reflect.Type, depth map[string]int, index []int, ss *st
 				}
 				for _, s := range p[1:] {
 					switch s {
-					//case "omitempty":
-					//  fs.omitempty = true
+					case "omitempty":
+						fs.omitEmpty = true
+					case "json":
+						fs.json = true
+					case "prefix":
+						nested = true
+					case "unixtime", "unixmilli", "unixnano":
+						if f.Type != timeType {
+							panic(errors.New("redigo: field flag " + s + " requires time.Time field for type " + t.Name()))
+						}
+						fs.timeUnit = timeUnits[s]
 					default:
 						panic(errors.New("redigo: unknown field flag " + s + " for type " + t.Name()))
 					}
 				}
 			}
+			fs.name = prefix + fs.name
+			if nested {
+				st := f.Type
+				if st.Kind() == reflect.Ptr {
+					st = st.Elem()
+				}
+				if st.Kind() != reflect.Struct {
+					panic(errors.New("redigo: field flag prefix requires struct field for type " + t.Name()))
+				}
+				for _, pt := range parents {
+					if pt == st {
+						panic(errors.New("redigo: field flag prefix on recursive type " + st.Name()))
+					}
+				}
+				compileStructSpec(st, depth, append(index, i), fs.name+".", append(parents, t), ss)
+				continue
+			}
 			d, found := depth[fs.name]
 			if !found {
 				d = 1 << 30
@@ -286,6 +331,15 @@ func compileStructSpec(t reflect.Type, depth map[string]int, index []int, ss *st
 	}
 }
 
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	timeUnits = map[string]time.Duration{
+		"unixtime":  time.Second,
+		"unixmilli": time.Millisecond,
+		"unixnano":  time.Nanosecond,
+	}
+)
+
 var (
 	structSpecMutex  sync.RWMutex
 	structSpecCache  = make(map[reflect.Type]*structSpec)
@@ -309,11 +363,128 @@ func structSpecForType(t reflect.Type) *structSpec {
 	}
 
 	ss = &structSpec{m: make(map[string]*fieldSpec)}
-	compileStructSpec(t, make(map[string]int), nil, ss)
+	compileStructSpec(t, make(map[string]int), nil, "", nil, ss)
 	structSpecCache[t] = ss
 	return ss
 }
 
+// fieldByIndex returns the field of struct v with the given index,
+// allocating the structs of nil pointer fields along the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldByIndexNoAlloc returns the field of struct v with the given index. It
+// returns false if the field is in a struct pointed to by a nil pointer.
+func fieldByIndexNoAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// convertAssignField copies the value s to the struct field f.
+func convertAssignField(f reflect.Value, fs *fieldSpec, s interface{}) error {
+	if fs.json {
+		return convertAssignJSON(f, fs, s)
+	}
+	if reflect.PtrTo(f.Type()).Implements(redisScannerType) {
+		return f.Addr().Interface().(RedisScanner).RedisScan(s)
+	}
+	if f.Type() == timeType {
+		return convertAssignTime(f, fs, s)
+	}
+	switch s := s.(type) {
+	case nil:
+		// ignore
+		return nil
+	case []byte:
+		return convertAssignBytes(f, s)
+	case int64:
+		return convertAssignInt(f, s)
+	default:
+		return cannotConvert(f, s)
+	}
+}
+
+// convertAssignJSON decodes the JSON bulk value s to the field f.
+func convertAssignJSON(f reflect.Value, fs *fieldSpec, s interface{}) error {
+	switch s := s.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if err := json.Unmarshal(s, f.Addr().Interface()); err != nil {
+			return fmt.Errorf("redigo: cannot decode JSON for field %s: %v", fs.name, err)
+		}
+		return nil
+	default:
+		return cannotConvert(f, s)
+	}
+}
+
+// convertAssignTime copies the value s to the time.Time field f. Integers
+// are interpreted as a count of fs.timeUnit since the Unix epoch, or seconds
+// if the field has no unit. Bulk values are parsed as RFC 3339 times unless
+// the field has a unit.
+func convertAssignTime(f reflect.Value, fs *fieldSpec, s interface{}) error {
+	unit := fs.timeUnit
+	var n int64
+	switch s := s.(type) {
+	case nil:
+		return nil
+	case int64:
+		if unit == 0 {
+			unit = time.Second
+		}
+		n = s
+	case []byte:
+		if unit == 0 {
+			t, err := time.Parse(time.RFC3339Nano, string(s))
+			if err != nil {
+				return fmt.Errorf("redigo: cannot parse %q as RFC 3339 time for field %s", s, fs.name)
+			}
+			f.Set(reflect.ValueOf(t))
+			return nil
+		}
+		var err error
+		if n, err = strconv.ParseInt(string(s), 10, 64); err != nil {
+			return fmt.Errorf("redigo: cannot parse %q as Unix time for field %s", s, fs.name)
+		}
+	default:
+		return cannotConvert(f, s)
+	}
+	// Split n into seconds and a remainder so that the conversion does not
+	// overflow time.Duration.
+	perSecond := int64(time.Second / unit)
+	f.Set(reflect.ValueOf(time.Unix(n/perSecond, n%perSecond*int64(unit))))
+	return nil
+}
+
+// timeArg returns the argument for the time.Time field value t in the format
+// read by convertAssignTime.
+func timeArg(fs *fieldSpec, t time.Time) interface{} {
+	if fs.timeUnit == 0 {
+		return t.Format(time.RFC3339Nano)
+	}
+	perSecond := int64(time.Second / fs.timeUnit)
+	return t.Unix()*perSecond + int64(t.Nanosecond())/int64(fs.timeUnit)
+}
+
 // ScanStruct scans a multi-bulk src containing alternating names and values to
 // a struct. The HGETALL and CONFIG GET commands return replies in this format.
 //
@@ -324,9 +495,29 @@ func structSpecForType(t reflect.Type) *structSpec {
 //
 // Fields with the tag redis:"-" are ignored.
 //
-// Integer, float boolean string and []byte fields are supported. Scan uses
-// the standard strconv package to convert bulk values to numeric and boolean
-// types.
+// The prefix tag option maps the fields of a nested struct or pointer to
+// struct to names with the field name and a dot as prefix. Pointers are
+// allocated when a value for one of the nested fields is scanned:
+//
+//      Addr *Address `redis:"addr,prefix"` // addr.city, addr.zip, ...
+//
+// The json tag option decodes the value of the field from JSON with the
+// encoding/json package:
+//
+//      Tags []string `redis:"tags,json"`
+//
+// Integer, float boolean string, []byte and time.Time fields are supported.
+// Fields of types whose pointer implements RedisScanner are decoded by the
+// RedisScan method.
+//
+// Times are parsed from RFC 3339 strings by default. The unixtime, unixmilli
+// and unixnano tag options parse times from integer seconds, milliseconds or
+// nanoseconds since the Unix epoch:
+//
+//      Created time.Time `redis:"created,unixtime"`
+//
+// ScanStruct uses the standard strconv package to convert bulk values to
+// numeric and boolean types.
 //
 // If the multi-bulk value is nil, then the corresponding field is not
 // modified.
@@ -351,25 +542,94 @@ func ScanStruct(src []interface{}, dest interface{}) error {
 		if fs == nil {
 			continue
 		}
-		f := d.FieldByIndex(fs.index)
-		var err error
-		switch s := src[i+1].(type) {
-		case nil:
-			// ignore
-		case []byte:
-			err = convertAssignBytes(f, s)
-		case int64:
-			err = convertAssignInt(f, s)
-		default:
-			err = cannotConvert(f, s)
-		}
-		if err != nil {
+		if err := convertAssignField(fieldByIndex(d, fs.index), fs, src[i+1]); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+var errScanSliceValue = errors.New("redigo: ScanSlice dest must be non-nil pointer to a struct slice")
+
+// ScanSlice scans multi-bulk src to the slice pointed to by dest. The
+// elements of the dest slice must be structs or pointers to structs.
+//
+// Each group of len(fieldNames) consecutive values in src is copied to one
+// struct, the first value to the field named by fieldNames[0] and so on. The
+// 'redis' field tag overrides field names as in ScanStruct. If fieldNames is
+// empty, then the values are copied to the fields in the order that the
+// fields are declared in the struct. A typical use is scanning the reply of
+// SORT with several GET patterns.
+//
+// If the multi-bulk value is nil, then the corresponding field is not
+// modified.
+func ScanSlice(src []interface{}, dest interface{}, fieldNames ...string) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Ptr || d.IsNil() {
+		return errScanSliceValue
+	}
+	d = d.Elem()
+	if d.Kind() != reflect.Slice {
+		return errScanSliceValue
+	}
+
+	isPtr := false
+	t := d.Type().Elem()
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		isPtr = true
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return errScanSliceValue
+	}
+
+	ss := structSpecForType(t)
+	fss := ss.l
+	if len(fieldNames) > 0 {
+		fss = make([]*fieldSpec, len(fieldNames))
+		for i, name := range fieldNames {
+			fss[i] = ss.m[name]
+			if fss[i] == nil {
+				return fmt.Errorf("redigo: ScanSlice bad field name %s", name)
+			}
+		}
+	}
+	if len(fss) == 0 {
+		return errors.New("redigo: ScanSlice no struct fields")
+	}
+	if len(src)%len(fss) != 0 {
+		return fmt.Errorf("redigo: ScanSlice length of src (%d) is not a multiple of the number of fields (%d)", len(src), len(fss))
+	}
+
+	n := len(src) / len(fss)
+	ensureLen(d, n)
+	for i := 0; i < n; i++ {
+		e := d.Index(i)
+		if isPtr {
+			if e.IsNil() {
+				e.Set(reflect.New(t))
+			}
+			e = e.Elem()
+		}
+		for j, fs := range fss {
+			if err := convertAssignField(fieldByIndex(e, fs.index), fs, src[i*len(fss)+j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ensureLen sets the length of the slice d to n, allocating a new slice if
+// the capacity of d is less than n.
+func ensureLen(d reflect.Value, n int) {
+	if n > d.Cap() {
+		d.Set(reflect.MakeSlice(d.Type(), n, n))
+	} else {
+		d.SetLen(n)
+	}
+}
+
 // Args is a helper for constructing command arguments from structured values.
 type Args []interface{}
 
@@ -381,32 +641,67 @@ func (args Args) Add(value interface{}) Args {
 // AddFlat returns the result of appending the flattened value of v to args.
 //
 // Maps are flattened by appending the alternating keys and map values to args.
+// Any map type is supported, for example map[string]int for setting hash
+// fields to counters with HMSET. If the keys are strings or numbers, then the
+// pairs are appended in sorted key order. Otherwise, the pairs are appended in
+// Go map iteration order.
 //
 // Slices are flattened by appending the slice elements to args.
 //
 // Structs are flattened by appending the alternating field names and field
 // values to args. If v is a nil struct pointer, then nothing is appended. The
 // 'redis' field tag overrides struct field names. See ScanStruct for more
-// information on the use of the 'redis' field tag.
+// information on the use of the 'redis' field tag. Fields with the omitempty
+// tag option are skipped if the field value is false, 0, a nil pointer, a nil
+// interface value, or an empty array, slice, map or string:
+//
+//      Field string `redis:"myName,omitempty"`
+//
+// The fields of nested structs with the prefix tag option are appended with
+// prefixed names. Nothing is appended for a nil pointer to a nested struct.
+// Fields with the json tag option are encoded with the encoding/json package.
+// Time fields are encoded as RFC 3339 strings, or as integers for fields with
+// the unixtime, unixmilli or unixnano tag options, so that they can be read
+// back by ScanStruct. A field that cannot be encoded, for example because it
+// contains a NaN float, is skipped. Use AddFlatErr to find out about such
+// fields.
 //
 // Other types are appended to args as is.
 func (args Args) AddFlat(v interface{}) Args {
+	args, _ = args.addFlat(v)
+	return args
+}
+
+// AddFlatErr is like AddFlat, but returns an error if a field with the json
+// tag option cannot be encoded. On error, args is returned unmodified.
+func (args Args) AddFlatErr(v interface{}) (Args, error) {
+	flat, err := args.addFlat(v)
+	if err != nil {
+		return args, err
+	}
+	return flat, nil
+}
+
+func (args Args) addFlat(v interface{}) (Args, error) {
+	var err error
 	rv := reflect.ValueOf(v)
 	switch rv.Kind() {
 	case reflect.Struct:
-		args = flattenStruct(args, rv)
+		args, err = flattenStruct(args, rv)
 	case reflect.Slice:
 		for i := 0; i < rv.Len(); i++ {
 			args = append(args, rv.Index(i).Interface())
 		}
 	case reflect.Map:
-		for _, k := range rv.MapKeys() {
+		keys := rv.MapKeys()
+		sortKeys(keys)
+		for _, k := range keys {
 			args = append(args, k.Interface(), rv.MapIndex(k).Interface())
 		}
 	case reflect.Ptr:
 		if rv.Type().Elem().Kind() == reflect.Struct {
 			if !rv.IsNil() {
-				args = flattenStruct(args, rv.Elem())
+				args, err = flattenStruct(args, rv.Elem())
 			}
 		} else {
 			args = append(args, v)
@@ -414,14 +709,77 @@ func (args Args) AddFlat(v interface{}) Args {
 	default:
 		args = append(args, v)
 	}
-	return args
+	return args, err
 }
 
-func flattenStruct(args Args, v reflect.Value) Args {
+// sortKeys sorts map keys of string and numeric kinds. Keys of other kinds
+// are not modified.
+func sortKeys(keys []reflect.Value) {
+	if len(keys) == 0 {
+		return
+	}
+	var less func(a, b reflect.Value) bool
+	switch keys[0].Kind() {
+	case reflect.String:
+		less = func(a, b reflect.Value) bool { return a.String() < b.String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		less = func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Float32, reflect.Float64:
+		less = func(a, b reflect.Value) bool { return a.Float() < b.Float() }
+	default:
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+}
+
+// flattenStruct appends the fields of v to args. Fields that cannot be
+// encoded are skipped and the first encoding error is returned.
+func flattenStruct(args Args, v reflect.Value) (Args, error) {
+	var firstErr error
 	ss := structSpecForType(v.Type())
 	for _, fs := range ss.l {
-		fv := v.FieldByIndex(fs.index)
+		fv, ok := fieldByIndexNoAlloc(v, fs.index)
+		if !ok || fs.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if fs.json {
+			p, err := json.Marshal(fv.Interface())
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("redigo: cannot encode JSON for field %s: %v", fs.name, err)
+				}
+				continue
+			}
+			args = append(args, fs.name, p)
+			continue
+		}
+		if fv.Type() == timeType {
+			args = append(args, fs.name, timeArg(fs, fv.Interface().(time.Time)))
+			continue
+		}
 		args = append(args, fs.name, fv.Interface())
 	}
-	return args
+	return args, firstErr
+}
+
+// isEmptyValue returns true if v is empty as defined by the omitempty option
+// of the encoding/json package.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
 }
@@ -15,11 +15,16 @@
 package redis_test
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
 	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 var scanConversionTests = []struct {
@@ -183,6 +188,301 @@ func TestScanStruct(t *testing.T) {
 	}
 }
 
+type s2 struct {
+	Name  string
+	Score int  `redis:"score"`
+	Skip  bool `redis:"-"`
+	Ok    bool
+}
+
+func TestScanSlice(t *testing.T) {
+	reply := []interface{}{[]byte("a"), []byte("1"), int64(1), []byte("b"), nil, []byte("0")}
+
+	var values []s2
+	if err := redis.ScanSlice(reply, &values); err != nil {
+		t.Fatalf("ScanSlice returned error %v", err)
+	}
+	want := []s2{{Name: "a", Score: 1, Ok: true}, {Name: "b"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("ScanSlice returned %v, want %v", values, want)
+	}
+
+	var ptrs []*s2
+	if err := redis.ScanSlice(reply, &ptrs, "score", "Name", "Ok"); err == nil {
+		t.Fatal("ScanSlice with string score returned nil error")
+	}
+	ptrs = nil
+	reply = []interface{}{[]byte("1"), []byte("a"), []byte("2"), []byte("b")}
+	if err := redis.ScanSlice(reply, &ptrs, "score", "Name"); err != nil {
+		t.Fatalf("ScanSlice returned error %v", err)
+	}
+	if len(ptrs) != 2 || *ptrs[0] != (s2{Name: "a", Score: 1}) || *ptrs[1] != (s2{Name: "b", Score: 2}) {
+		t.Fatalf("ScanSlice returned %v", ptrs)
+	}
+
+	errorTests := []struct {
+		title      string
+		src        []interface{}
+		dest       interface{}
+		fieldNames []string
+	}{
+		{"short", reply[:3], &values, []string{"score", "Name"}},
+		{"field", reply, &values, []string{"Skip", "Name"}},
+		{"not pointer", reply, values, nil},
+		{"not slice", reply, &s2{}, nil},
+		{"not struct", reply, &[]string{}, nil},
+	}
+	for _, tt := range errorTests {
+		if err := redis.ScanSlice(tt.src, tt.dest, tt.fieldNames...); err == nil {
+			t.Errorf("ScanSlice(%s) returned nil error", tt.title)
+		}
+	}
+}
+
+type s3 struct {
+	Default time.Time
+	Sec     time.Time `redis:"sec,unixtime"`
+	Milli   time.Time `redis:"milli,unixmilli"`
+	Nano    time.Time `redis:"nano,unixnano"`
+}
+
+func TestScanStructTime(t *testing.T) {
+	want := time.Date(2013, 4, 5, 6, 7, 8, 9000000, time.UTC)
+	reply := []interface{}{
+		[]byte("Default"), []byte("2013-04-05T06:07:08.009Z"),
+		[]byte("sec"), int64(want.Unix()),
+		[]byte("milli"), []byte(strconv.FormatInt(want.UnixNano()/1e6, 10)),
+		[]byte("nano"), []byte(strconv.FormatInt(want.UnixNano(), 10)),
+	}
+	var v s3
+	if err := redis.ScanStruct(reply, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if !v.Default.Equal(want) || !v.Sec.Equal(want.Truncate(time.Second)) || !v.Milli.Equal(want) || !v.Nano.Equal(want) {
+		t.Errorf("ScanStruct returned %+v, want %v", v, want)
+	}
+
+	for _, reply := range [][]interface{}{
+		{[]byte("Default"), []byte("yesterday")},
+		{[]byte("sec"), []byte("2013-04-05T06:07:08Z")},
+	} {
+		err := redis.ScanStruct(reply, &v)
+		if err == nil || !strings.Contains(err.Error(), string(reply[1].([]byte))) {
+			t.Errorf("ScanStruct(%q) returned error %v, want parse error", reply[1], err)
+		}
+	}
+
+	// Times far from the epoch do not overflow.
+	far := time.Date(2500, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	reply = []interface{}{
+		[]byte("sec"), far.Unix(),
+		[]byte("milli"), far.Unix()*1000 + 6,
+	}
+	v = s3{}
+	if err := redis.ScanStruct(reply, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if !v.Sec.Equal(far.Truncate(time.Second)) || !v.Milli.Equal(far) {
+		t.Errorf("ScanStruct returned %v, %v, want %v", v.Sec, v.Milli, far)
+	}
+}
+
+func TestAddFlatTime(t *testing.T) {
+	ts := time.Date(2013, 4, 5, 6, 7, 8, 9000000, time.UTC)
+	v := s3{Default: ts, Sec: ts.Truncate(time.Second), Milli: ts, Nano: ts}
+	args := (redis.Args{}).AddFlat(&v)
+	want := redis.Args{
+		"Default", "2013-04-05T06:07:08.009Z",
+		"sec", ts.Unix(),
+		"milli", ts.UnixNano() / 1e6,
+		"nano", ts.UnixNano(),
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("AddFlat returned %v, want %v", args, want)
+	}
+
+	// Round trip the arguments through ScanStruct.
+	reply := make([]interface{}, len(args))
+	for i, arg := range args {
+		reply[i] = []byte(fmt.Sprint(arg))
+	}
+	var got s3
+	if err := redis.ScanStruct(reply, &got); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if !got.Default.Equal(v.Default) || !got.Sec.Equal(v.Sec) || !got.Milli.Equal(v.Milli) || !got.Nano.Equal(v.Nano) {
+		t.Errorf("ScanStruct returned %+v, want %+v", got, v)
+	}
+}
+
+type geo struct {
+	Lat float64 `redis:"lat"`
+	Lng float64 `redis:"lng"`
+}
+
+type address struct {
+	City string `redis:"city"`
+	Zip  string `redis:"zip"`
+	Geo  *geo   `redis:"geo,prefix"`
+}
+
+type person struct {
+	Name string   `redis:"name"`
+	Home address  `redis:"home,prefix"`
+	Work *address `redis:"work,prefix"`
+}
+
+func TestScanStructPrefix(t *testing.T) {
+	reply := []interface{}{
+		[]byte("name"), []byte("Ann"),
+		[]byte("home.city"), []byte("Oslo"),
+		[]byte("home.geo.lat"), []byte("59.9"),
+		[]byte("work.zip"), []byte("0150"),
+	}
+	var v person
+	if err := redis.ScanStruct(reply, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	want := person{
+		Name: "Ann",
+		Home: address{City: "Oslo", Geo: &geo{Lat: 59.9}},
+		Work: &address{Zip: "0150"},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("ScanStruct returned %+v, want %+v", v, want)
+	}
+
+	// Nested pointers are not allocated without a matching key.
+	v = person{}
+	if err := redis.ScanStruct([]interface{}{[]byte("home.zip"), []byte("0150"), []byte("city"), []byte("x")}, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if want := (person{Home: address{Zip: "0150"}}); !reflect.DeepEqual(v, want) {
+		t.Errorf("ScanStruct returned %+v, want %+v", v, want)
+	}
+
+	// Nothing is appended for nil nested pointers.
+	args := (redis.Args{}).AddFlat(&v)
+	wantArgs := redis.Args{"name", "", "home.city", "", "home.zip", "0150"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("AddFlat returned %v, want %v", args, wantArgs)
+	}
+}
+
+type jsonStruct struct {
+	Title string            `redis:"title"`
+	Tags  []string          `redis:"tags,json"`
+	Attrs map[string]int    `redis:"attrs,json,omitempty"`
+	Geo   *geo              `redis:"geo,json"`
+	Raw   json.RawMessage   `redis:"raw,json"`
+	Meta  map[string]string `redis:"meta,json"`
+}
+
+func TestJSONField(t *testing.T) {
+	v := jsonStruct{
+		Title: "t",
+		Tags:  []string{"a", "b"},
+		Geo:   &geo{Lat: 1.5, Lng: -2},
+		Raw:   json.RawMessage(`{"x":1}`),
+	}
+	args := (redis.Args{}).AddFlat(&v)
+	wantArgs := redis.Args{
+		"title", "t",
+		"tags", []byte(`["a","b"]`),
+		"geo", []byte(`{"Lat":1.5,"Lng":-2}`),
+		"raw", []byte(`{"x":1}`),
+		"meta", []byte(`null`),
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("AddFlat returned %q, want %q", args, wantArgs)
+	}
+
+	// Round trip the arguments through ScanStruct.
+	reply := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			reply[i] = []byte(arg)
+		default:
+			reply[i] = arg
+		}
+	}
+	var got jsonStruct
+	if err := redis.ScanStruct(reply, &got); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("ScanStruct returned %+v, want %+v", got, v)
+	}
+
+	err := redis.ScanStruct([]interface{}{[]byte("tags"), []byte("[1,")}, &got)
+	if err == nil || !strings.Contains(err.Error(), "tags") {
+		t.Errorf("ScanStruct with bad JSON returned %v, want error naming the field", err)
+	}
+}
+
+func TestJSONFieldEncodeError(t *testing.T) {
+	v := struct {
+		Title string             `redis:"title"`
+		Score map[string]float64 `redis:"score,json"`
+	}{"t", map[string]float64{"x": math.NaN()}}
+
+	// AddFlat skips the field that cannot be encoded.
+	if args := (redis.Args{"key"}).AddFlat(&v); !reflect.DeepEqual(args, redis.Args{"key", "title", "t"}) {
+		t.Errorf("AddFlat returned %v, want [key title t]", args)
+	}
+
+	args, err := (redis.Args{"key"}).AddFlatErr(&v)
+	if err == nil || !strings.Contains(err.Error(), "score") {
+		t.Errorf("AddFlatErr returned error %v, want error naming the field", err)
+	}
+	if !reflect.DeepEqual(args, redis.Args{"key"}) {
+		t.Errorf("AddFlatErr returned %v on error, want args unmodified", args)
+	}
+
+	v.Score = nil
+	if args, err := (redis.Args{}).AddFlatErr(&v); err != nil || len(args) != 4 {
+		t.Errorf("AddFlatErr returned %v, %v, want 4 args", args, err)
+	}
+}
+
+// b64 decodes base64 encoded bulk values.
+type b64 []byte
+
+func (b *b64) RedisScan(src interface{}) error {
+	p, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("b64: cannot scan %T", src)
+	}
+	d, err := base64.StdEncoding.DecodeString(string(p))
+	*b = d
+	return err
+}
+
+func TestRedisScanner(t *testing.T) {
+	var v struct {
+		Data b64 `redis:"data"`
+		N    int `redis:"n"`
+	}
+	if err := redis.ScanStruct([]interface{}{[]byte("data"), []byte("aGVsbG8="), []byte("n"), []byte("1")}, &v); err != nil {
+		t.Fatalf("ScanStruct returned error %v", err)
+	}
+	if string(v.Data) != "hello" || v.N != 1 {
+		t.Errorf("ScanStruct returned %q, %d, want hello, 1", v.Data, v.N)
+	}
+
+	var b b64
+	if _, err := redis.Scan([]interface{}{[]byte("d29ybGQ=")}, &b); err != nil || string(b) != "world" {
+		t.Errorf("Scan returned %q, %v, want world", b, err)
+	}
+	if _, err := redis.Scan([]interface{}{int64(1)}, &b); err == nil {
+		t.Error("Scan of integer to b64 returned nil error")
+	}
+	if err := redis.ScanStruct([]interface{}{[]byte("data"), []byte("!!")}, &v); err == nil {
+		t.Error("ScanStruct of invalid base64 returned nil error")
+	}
+}
+
 var argsTests = []struct {
 	title    string
 	actual   redis.Args
@@ -209,6 +509,53 @@ var argsTests = []struct {
 		redis.Args{}.Add(1).AddFlat([]string{"a", "b", "c"}).Add(2),
 		redis.Args{1, "a", "b", "c", 2},
 	},
+	{"map[string]int",
+		redis.Args{}.Add("key").AddFlat(map[string]int{"c": 3, "a": 1, "b": 2}),
+		redis.Args{"key", "a", 1, "b", 2, "c", 3},
+	},
+	{"map[string]float64",
+		redis.Args{}.AddFlat(map[string]float64{"y": 0.5, "x": 1.5}),
+		redis.Args{"x", 1.5, "y", 0.5},
+	},
+	{"map[string]string",
+		redis.Args{}.AddFlat(map[string]string{"b": "2", "a": "1"}),
+		redis.Args{"a", "1", "b", "2"},
+	},
+	{"map[int]uint",
+		redis.Args{}.AddFlat(map[int]uint{10: 1, -1: 2, 3: 3}),
+		redis.Args{-1, uint(2), 3, uint(3), 10, uint(1)},
+	},
+}
+
+type omitEmptyStruct struct {
+	S    string            `redis:"s,omitempty"`
+	I    int               `redis:"i,omitempty"`
+	U    uint              `redis:"u,omitempty"`
+	F    float64           `redis:"f,omitempty"`
+	B    bool              `redis:"b,omitempty"`
+	P    *int              `redis:"p,omitempty"`
+	X    interface{}       `redis:"x,omitempty"`
+	Sl   []byte            `redis:"sl,omitempty"`
+	M    map[string]string `redis:"m,omitempty"`
+	A    [0]int            `redis:"a,omitempty"`
+	Kept int               `redis:"kept"`
+}
+
+func TestArgsOmitEmpty(t *testing.T) {
+	if args := (redis.Args{}).AddFlat(&omitEmptyStruct{}); !reflect.DeepEqual(args, redis.Args{"kept", 0}) {
+		t.Errorf("AddFlat(empty) = %v, want [kept 0]", args)
+	}
+
+	n := 0
+	v := omitEmptyStruct{
+		S: "s", I: -1, U: 1, F: 0.5, B: true, P: &n, X: 0,
+		Sl: []byte("sl"), M: map[string]string{"k": "v"}, Kept: 1,
+	}
+	want := redis.Args{"s", "s", "i", -1, "u", uint(1), "f", 0.5, "b", true, "p", &n, "x", 0,
+		"sl", []byte("sl"), "m", map[string]string{"k": "v"}, "kept", 1}
+	if args := (redis.Args{}).AddFlat(&v); !reflect.DeepEqual(args, want) {
+		t.Errorf("AddFlat(non-empty) = %v, want %v", args, want)
+	}
 }
 
 func TestArgs(t *testing.T) {
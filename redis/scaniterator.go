@@ -0,0 +1,164 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+)
+
+// ScanIterator iterates over the elements returned by the SCAN, HSCAN, SSCAN
+// and ZSCAN commands, issuing the command again with the returned cursor
+// until the server reports that the iteration is complete.
+//
+//  it := redis.NewScanIterator(c, "user:*", 100)
+//  for it.Next() {
+//      fmt.Println(it.Val())
+//  }
+//  if err := it.Err(); err != nil {
+//      // handle error
+//  }
+//
+// As documented for the commands, an element may be returned more than once.
+type ScanIterator struct {
+	c     Conn
+	cmd   string
+	key   string
+	match string
+	count int
+	typ   string
+	pairs bool
+
+	cursor  string
+	started bool
+	buf     []string
+	val     string
+	value   string
+	err     error
+}
+
+// NewScanIterator returns an iterator over the keys of the current database
+// using the SCAN command. If match is not "", then only keys matching the
+// pattern are returned. If count is greater than zero, then it is sent as a
+// hint of the number of keys to return with each command.
+func NewScanIterator(c Conn, match string, count int) *ScanIterator {
+	return &ScanIterator{c: c, cmd: "SCAN", match: match, count: count}
+}
+
+// NewScanIteratorType returns an iterator over the keys of the current
+// database holding values of type typ, such as "string" or "zset", using the
+// TYPE option of the SCAN command. The option requires Redis 6.0 or later.
+// See NewScanIterator for the meaning of match and count.
+func NewScanIteratorType(c Conn, match string, count int, typ string) *ScanIterator {
+	return &ScanIterator{c: c, cmd: "SCAN", match: match, count: count, typ: typ}
+}
+
+// NewHScanIterator returns an iterator over the fields of the hash at key
+// using the HSCAN command. Value returns the value of the field. See
+// NewScanIterator for the meaning of match and count.
+func NewHScanIterator(c Conn, key string, match string, count int) *ScanIterator {
+	return &ScanIterator{c: c, cmd: "HSCAN", key: key, match: match, count: count, pairs: true}
+}
+
+// NewSScanIterator returns an iterator over the members of the set at key
+// using the SSCAN command. See NewScanIterator for the meaning of match and
+// count.
+func NewSScanIterator(c Conn, key string, match string, count int) *ScanIterator {
+	return &ScanIterator{c: c, cmd: "SSCAN", key: key, match: match, count: count}
+}
+
+// NewZScanIterator returns an iterator over the members of the sorted set at
+// key using the ZSCAN command. Value returns the score of the member. See
+// NewScanIterator for the meaning of match and count.
+func NewZScanIterator(c Conn, key string, match string, count int) *ScanIterator {
+	return &ScanIterator{c: c, cmd: "ZSCAN", key: key, match: match, count: count, pairs: true}
+}
+
+// Next advances the iterator to the next element, which is then available
+// through the Val and Value methods. Next returns false when the iteration
+// is complete or an error occurred.
+func (it *ScanIterator) Next() bool {
+	for len(it.buf) == 0 {
+		if it.err != nil || (it.started && it.cursor == "0") {
+			return false
+		}
+		it.err = it.fetch()
+	}
+	it.val, it.buf = it.buf[0], it.buf[1:]
+	if it.pairs {
+		it.value, it.buf = it.buf[0], it.buf[1:]
+	}
+	return true
+}
+
+// Val returns the current key, hash field or set member.
+func (it *ScanIterator) Val() string {
+	return it.val
+}
+
+// Value returns the value of the current hash field for HSCAN and the score
+// of the current member for ZSCAN. For other commands, Value returns "".
+func (it *ScanIterator) Value() string {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ScanIterator) Err() error {
+	return it.err
+}
+
+func (it *ScanIterator) fetch() error {
+	if !it.started {
+		it.cursor = "0"
+		it.started = true
+	}
+	var args []interface{}
+	if it.cmd != "SCAN" {
+		args = append(args, it.key)
+	}
+	args = append(args, it.cursor)
+	if it.match != "" {
+		args = append(args, "MATCH", it.match)
+	}
+	if it.count > 0 {
+		args = append(args, "COUNT", it.count)
+	}
+	if it.typ != "" {
+		args = append(args, "TYPE", it.typ)
+	}
+	reply, err := Values(it.c.Do(it.cmd, args...))
+	if e, ok := err.(Error); ok && it.typ != "" && e == "ERR syntax error" {
+		// Servers before 6.0 reject the TYPE option.
+		return fmt.Errorf("redigo: SCAN TYPE requires Redis 6.0 or later: %v", err)
+	}
+	if err != nil {
+		return err
+	}
+	if len(reply) != 2 {
+		return fmt.Errorf("redigo: unexpected %s reply with %d elements", it.cmd, len(reply))
+	}
+	cursor, err := String(reply[0], nil)
+	if err != nil {
+		return err
+	}
+	buf, err := Strings(reply[1], nil)
+	if err != nil {
+		return err
+	}
+	if it.pairs && len(buf)%2 != 0 {
+		return fmt.Errorf("redigo: %s expects even number of elements", it.cmd)
+	}
+	it.cursor, it.buf = cursor, buf
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestScanIterator(t *testing.T) {
+	var mu sync.Mutex
+	var cmds []string
+	// Each page is the reply to a cursor, the last page ends the iteration
+	// with cursor 0.
+	pages := map[string]string{
+		"0": "*2\r\n$1\r\n7\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n",
+		"7": "*2\r\n$1\r\n9\r\n*0\r\n",
+		"9": "*2\r\n$1\r\n0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n",
+	}
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmds = append(cmds, strings.Join(args, " "))
+		mu.Unlock()
+		cursor := args[1]
+		if args[0] != "SCAN" {
+			cursor = args[2]
+		}
+		if args[0] == "SSCAN" && cursor == "7" {
+			return "-ERR broken\r\n"
+		}
+		return pages[cursor]
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		it   *redis.ScanIterator
+		vals []string
+		cmds []string
+	}{
+		{
+			redis.NewScanIterator(c, "", 0),
+			[]string{"a", "1", "b", "2"},
+			[]string{"SCAN 0", "SCAN 7", "SCAN 9"},
+		},
+		{
+			redis.NewHScanIterator(c, "h", "f*", 10),
+			[]string{"a=1", "b=2"},
+			[]string{"HSCAN h 0 MATCH f* COUNT 10", "HSCAN h 7 MATCH f* COUNT 10", "HSCAN h 9 MATCH f* COUNT 10"},
+		},
+		{
+			redis.NewZScanIterator(c, "z", "", 0),
+			[]string{"a=1", "b=2"},
+			[]string{"ZSCAN z 0", "ZSCAN z 7", "ZSCAN z 9"},
+		},
+		{
+			// The key is sent even if it is empty.
+			redis.NewZScanIterator(c, "", "", 0),
+			[]string{"a=1", "b=2"},
+			[]string{"ZSCAN  0", "ZSCAN  7", "ZSCAN  9"},
+		},
+	}
+	for _, tt := range tests {
+		mu.Lock()
+		cmds = nil
+		mu.Unlock()
+		var vals []string
+		for tt.it.Next() {
+			if tt.it.Value() != "" {
+				vals = append(vals, tt.it.Val()+"="+tt.it.Value())
+			} else {
+				vals = append(vals, tt.it.Val())
+			}
+		}
+		if err := tt.it.Err(); err != nil {
+			t.Errorf("%v: Err() = %v", tt.cmds[0], err)
+		}
+		if !reflect.DeepEqual(vals, tt.vals) {
+			t.Errorf("%v: values %v, want %v", tt.cmds[0], vals, tt.vals)
+		}
+		mu.Lock()
+		if !reflect.DeepEqual(cmds, tt.cmds) {
+			t.Errorf("commands %q, want %q", cmds, tt.cmds)
+		}
+		mu.Unlock()
+		if tt.it.Next() {
+			t.Errorf("%v: Next() returned true after the iteration completed", tt.cmds[0])
+		}
+	}
+
+	it := redis.NewSScanIterator(c, "s", "", 0)
+	var n int
+	for it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("SSCAN returned %d members before the error, want 2", n)
+	}
+	if err := it.Err(); err == nil || err.Error() != "ERR broken" {
+		t.Errorf("SSCAN Err() = %v, want ERR broken", err)
+	}
+}
+
+func TestScanIteratorType(t *testing.T) {
+	var mu sync.Mutex
+	var cmds []string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmds = append(cmds, strings.Join(args, " "))
+		mu.Unlock()
+		if args[len(args)-1] == "old" {
+			return "-ERR syntax error\r\n"
+		}
+		return "*2\r\n$1\r\n0\r\n*1\r\n$1\r\na\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	it := redis.NewScanIteratorType(c, "k*", 10, "zset")
+	var vals []string
+	for it.Next() {
+		vals = append(vals, it.Val())
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(vals, want) {
+		t.Errorf("values %v, want %v", vals, want)
+	}
+	mu.Lock()
+	if want := []string{"SCAN 0 MATCH k* COUNT 10 TYPE zset"}; !reflect.DeepEqual(cmds, want) {
+		t.Errorf("commands %q, want %q", cmds, want)
+	}
+	mu.Unlock()
+
+	it = redis.NewScanIteratorType(c, "", 0, "old")
+	if it.Next() {
+		t.Error("Next() returned true for a rejected TYPE option")
+	}
+	if err := it.Err(); err == nil || !strings.Contains(err.Error(), "requires Redis 6.0") {
+		t.Errorf("Err() = %v, want version error", err)
+	}
+}
@@ -17,6 +17,8 @@ package redis
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"strings"
 )
@@ -27,8 +29,13 @@ type Script struct {
 	keyCount int
 	src      string
 	hash     string
+	readOnly bool
 }
 
+// ErrReadOnlyScriptUnsupported is returned by the Do method of a read-only
+// script when the server does not support the EVALSHA_RO and EVAL_RO commands.
+var ErrReadOnlyScriptUnsupported = errors.New("redigo: EVALSHA_RO and EVAL_RO require Redis 7.0 or later")
+
 // NewScript returns a new script object. If keyCount is greater than or equal
 // to zero, then the count is automatically inserted in the EVAL command
 // argument list. If keyCount is less than zero, then the application supplies
@@ -37,7 +44,31 @@ type Script struct {
 func NewScript(keyCount int, src string) *Script {
 	h := sha1.New()
 	io.WriteString(h, src)
-	return &Script{keyCount, src, hex.EncodeToString(h.Sum(nil))}
+	return &Script{keyCount: keyCount, src: src, hash: hex.EncodeToString(h.Sum(nil))}
+}
+
+// NewReadOnlyScript returns a new script object for a script that does not
+// modify data. Read-only scripts are evaluated with the EVALSHA_RO and
+// EVAL_RO commands added in Redis 7 so that they can run on slaves. If the
+// server does not know these commands, the Do method returns
+// ErrReadOnlyScriptUnsupported. See NewScript for the meaning of keyCount.
+func NewReadOnlyScript(keyCount int, src string) *Script {
+	s := NewScript(keyCount, src)
+	s.readOnly = true
+	return s
+}
+
+// The commands used to evaluate the script by hash and by source.
+func (s *Script) commands() (evalsha string, eval string) {
+	if s.readOnly {
+		return "EVALSHA_RO", "EVAL_RO"
+	}
+	return "EVALSHA", "EVAL"
+}
+
+func isUnknownCommand(err error) bool {
+	e, ok := err.(Error)
+	return ok && strings.HasPrefix(string(e), "ERR unknown command")
 }
 
 func (s *Script) args(spec string, keysAndArgs []interface{}) []interface{} {
@@ -58,25 +89,34 @@ func (s *Script) args(spec string, keysAndArgs []interface{}) []interface{} {
 // Do evalutes the script. Under the covers, Do optimistically evaluates the
 // script using the EVALSHA command. If the command fails because the script is
 // not loaded, then Do evaluates the script using the EVAL command (thus
-// causing the script to load).
+// causing the script to load). Read-only scripts use EVALSHA_RO and EVAL_RO
+// instead and are never evaluated with EVALSHA or EVAL.
 func (s *Script) Do(c Conn, keysAndArgs ...interface{}) (interface{}, error) {
-	v, err := c.Do("EVALSHA", s.args(s.hash, keysAndArgs)...)
-	if e, ok := err.(Error); ok && strings.HasPrefix(string(e), "NOSCRIPT ") {
-		v, err = c.Do("EVAL", s.args(s.src, keysAndArgs)...)
+	evalsha, eval := s.commands()
+	v, err := c.Do(evalsha, s.args(s.hash, keysAndArgs)...)
+	if s.readOnly && isUnknownCommand(err) {
+		return nil, ErrReadOnlyScriptUnsupported
+	}
+	if e, ok := err.(Error); ok && e.Code() == "NOSCRIPT" {
+		v, err = c.Do(eval, s.args(s.src, keysAndArgs)...)
 	}
 	return v, err
 }
 
 // SendHash evaluates the script without waiting for the reply. The script is
-// evaluated with the EVALSHA command. The application must ensure that the
-// script is loaded by a previous call to Send, Do or Load methods.
+// evaluated with the EVALSHA command, or EVALSHA_RO for a read-only script.
+// The application must ensure that the script is loaded by a previous call to
+// Send, Do or Load methods.
 func (s *Script) SendHash(c Conn, keysAndArgs ...interface{}) error {
-	return c.Send("EVALSHA", s.args(s.hash, keysAndArgs)...)
+	evalsha, _ := s.commands()
+	return c.Send(evalsha, s.args(s.hash, keysAndArgs)...)
 }
 
-// Send evaluates the script without waiting for the reply.
+// Send evaluates the script without waiting for the reply. The script is
+// evaluated with the EVAL command, or EVAL_RO for a read-only script.
 func (s *Script) Send(c Conn, keysAndArgs ...interface{}) error {
-	return c.Send("EVAL", s.args(s.src, keysAndArgs)...)
+	_, eval := s.commands()
+	return c.Send(eval, s.args(s.src, keysAndArgs)...)
 }
 
 // Load loads the script without evaluating it.
@@ -84,3 +124,45 @@ func (s *Script) Load(c Conn) error {
 	_, err := c.Do("SCRIPT", "LOAD", s.src)
 	return err
 }
+
+// LoadScripts loads the scripts without evaluating them. The SCRIPT LOAD
+// commands are pipelined in a single round trip and the hash returned by
+// the server is checked against the hash of each script. The scripts are
+// not modified, so they can be used by other goroutines while LoadScripts
+// runs. LoadScripts returns the first error, which includes the source of
+// the script that failed to load. Use LoadScripts at startup to find errors
+// in all scripts before they are first evaluated.
+//
+// LoadScripts only warms up the script cache of the server and records
+// nothing in the scripts. The Do method always tries EVALSHA first, which
+// succeeds without falling back to EVAL once the server has loaded the
+// script. Scripts are loaded per server, so a connection to another server,
+// or to a server that was restarted or ran SCRIPT FLUSH, still falls back to
+// EVAL on first use.
+func LoadScripts(c Conn, scripts ...*Script) error {
+	for _, s := range scripts {
+		if err := c.Send("SCRIPT", "LOAD", s.src); err != nil {
+			return err
+		}
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	var firstErr error
+	for _, s := range scripts {
+		hash, err := String(c.Receive())
+		if err == nil {
+			if hash != s.hash && firstErr == nil {
+				firstErr = fmt.Errorf("redigo: loading script %q: server returned hash %s, want %s", s.src, hash, s.hash)
+			}
+			continue
+		}
+		if _, ok := err.(Error); !ok {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("redigo: loading script %q: %v", s.src, err)
+		}
+	}
+	return firstErr
+}
@@ -15,9 +15,13 @@
 package redis_test
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -86,3 +90,135 @@ func TestScript(t *testing.T) {
 	}
 
 }
+
+func TestLoadScripts(t *testing.T) {
+	var loaded []string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch {
+		case len(args) == 3 && args[0] == "SCRIPT" && args[1] == "LOAD":
+			if strings.Contains(args[2], "syntax error") {
+				return "-ERR Error compiling script\r\n"
+			}
+			if args[2] == "bad hash" {
+				return "$40\r\n" + strings.Repeat("0", 40) + "\r\n"
+			}
+			loaded = append(loaded, args[2])
+			h := sha1.Sum([]byte(args[2]))
+			return "$40\r\n" + hex.EncodeToString(h[:]) + "\r\n"
+		case args[0] == "EVALSHA":
+			return ":1\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	s1 := redis.NewScript(0, "return 1")
+	s2 := redis.NewScript(0, "return 2")
+	if err := redis.LoadScripts(c, s1, s2); err != nil {
+		t.Fatalf("LoadScripts returned %v", err)
+	}
+	if want := []string{"return 1", "return 2"}; !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loaded %q, want %q", loaded, want)
+	}
+	if v, err := redis.Int(s1.Do(c)); err != nil || v != 1 {
+		t.Errorf("s1.Do(c) = %v, %v, want 1, nil", v, err)
+	}
+
+	bad := redis.NewScript(0, "syntax error")
+	err = redis.LoadScripts(c, s1, bad, s2)
+	if err == nil || !strings.Contains(err.Error(), `"syntax error"`) {
+		t.Errorf("LoadScripts returned %v, want error with script source", err)
+	}
+
+	// The connection is still in sync after the error.
+	if v, err := redis.Int(s2.Do(c)); err != nil || v != 1 {
+		t.Errorf("s2.Do(c) = %v, %v, want 1, nil", v, err)
+	}
+
+	err = redis.LoadScripts(c, redis.NewScript(0, "bad hash"))
+	if err == nil || !strings.Contains(err.Error(), "server returned hash") {
+		t.Errorf("LoadScripts returned %v, want hash mismatch error", err)
+	}
+}
+
+func TestReadOnlyScript(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		cmds     []string
+		noRO     bool
+		noScript bool
+	)
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		cmds = append(cmds, args[0])
+		switch args[0] {
+		case "EVALSHA_RO", "EVAL_RO":
+			if noRO {
+				return "-ERR unknown command '" + args[0] + "'\r\n"
+			}
+			if noScript && args[0] == "EVALSHA_RO" {
+				return "-NOSCRIPT No matching script.\r\n"
+			}
+		case "EVALSHA":
+			if noScript {
+				return "-NOSCRIPT No matching script.\r\n"
+			}
+		}
+		return ":1\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		noRO, noScript bool
+		want           []string
+		err            error
+	}{
+		{false, false, []string{"EVALSHA_RO"}, nil},
+		{false, true, []string{"EVALSHA_RO", "EVAL_RO"}, nil},
+		{true, false, []string{"EVALSHA_RO"}, redis.ErrReadOnlyScriptUnsupported},
+		{true, true, []string{"EVALSHA_RO"}, redis.ErrReadOnlyScriptUnsupported},
+	}
+	script := redis.NewReadOnlyScript(1, "return redis.call('get', KEYS[1])")
+	for _, tt := range tests {
+		mu.Lock()
+		cmds, noRO, noScript = nil, tt.noRO, tt.noScript
+		mu.Unlock()
+		if _, err := script.Do(c, "key"); err != tt.err {
+			t.Errorf("Do with noRO=%v noScript=%v returned %v, want %v", tt.noRO, tt.noScript, err, tt.err)
+		}
+		mu.Lock()
+		if !reflect.DeepEqual(cmds, tt.want) {
+			t.Errorf("Do with noRO=%v noScript=%v sent %v, want %v", tt.noRO, tt.noScript, cmds, tt.want)
+		}
+		mu.Unlock()
+	}
+
+	mu.Lock()
+	cmds, noRO, noScript = nil, false, false
+	mu.Unlock()
+	script.SendHash(c, "key")
+	script.Send(c, "key")
+	redis.NewScript(1, "return 1").Send(c, "key")
+	c.Flush()
+	for i := 0; i < 3; i++ {
+		c.Receive()
+	}
+	mu.Lock()
+	if want := []string{"EVALSHA_RO", "EVAL_RO", "EVAL"}; !reflect.DeepEqual(cmds, want) {
+		t.Errorf("SendHash, Send sent %v, want %v", cmds, want)
+	}
+	mu.Unlock()
+}
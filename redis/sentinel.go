@@ -0,0 +1,198 @@
+// Copyright 2013 Tahir Hashmi, Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout for connecting to a sentinel and waiting for its replies.
+var sentinelTimeout = 5 * time.Second
+
+var ErrNoSentinel = errors.New("None of the given sentinels could be queried")
+var ErrNotSentinelCluster = errors.New("The cluster was not created by NewClusterFromSentinel")
+
+// sentinelConfig records how a cluster was discovered so that the
+// topology can be refreshed later.
+type sentinelConfig struct {
+	addrs      []string
+	masterName string
+	dial       func(addr string) (*Pool, error)
+
+	// mu serializes refreshes and protects fields defined below.
+	mu         sync.Mutex
+	masterAddr string
+	slaveAddrs []string
+	slavePools map[string]*Pool
+}
+
+// Create a cluster for the master named masterName and its slaves as
+// reported by Redis Sentinel. The sentinels are tried in the given order
+// until one of them answers. The dial function is called with the
+// host:port address of each node to create the pool for that node.
+func NewClusterFromSentinel(sentinelAddrs []string, masterName string, dial func(addr string) (*Pool, error)) (*Cluster, error) {
+	c := &Cluster{sentinel: &sentinelConfig{
+		addrs:      append([]string(nil), sentinelAddrs...),
+		masterName: masterName,
+		dial:       dial,
+	}}
+	if err := c.Refresh(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Query the sentinels again and apply any change in topology to a
+// cluster created by NewClusterFromSentinel. A new master replaces the
+// old one using ReplaceMaster. If the set of slaves changed, pools are
+// created for the new slaves and the slaves are set using SetSlaves.
+// Pools of slaves that are still up are kept.
+func (c *Cluster) Refresh() error {
+	s := c.sentinel
+	if s == nil {
+		return ErrNotSentinelCluster
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	masterAddr, slaveAddrs, err := s.query()
+	if err != nil {
+		return err
+	}
+
+	if masterAddr != s.masterAddr {
+		p, err := s.dial(masterAddr)
+		if err != nil {
+			return err
+		}
+		if err := c.ReplaceMaster(p); err != nil {
+			return err
+		}
+		s.masterAddr = masterAddr
+	}
+
+	if !equalStrings(slaveAddrs, s.slaveAddrs) {
+		pools := make([]*Pool, 0, len(slaveAddrs))
+		var created []*Pool
+		for _, addr := range slaveAddrs {
+			p := s.slavePools[addr]
+			if p == nil {
+				var err error
+				if p, err = s.dial(addr); err != nil {
+					for _, p := range created {
+						p.Close()
+					}
+					return err
+				}
+				created = append(created, p)
+			}
+			pools = append(pools, p)
+		}
+		if err := c.SetSlaves(pools); err != nil {
+			return err
+		}
+		s.slaveAddrs = slaveAddrs
+		s.slavePools = make(map[string]*Pool, len(pools))
+		for i, addr := range slaveAddrs {
+			s.slavePools[addr] = pools[i]
+		}
+	}
+	return nil
+}
+
+// Ask each sentinel in turn for the master address and the addresses of
+// the slaves that are up. The slave addresses are sorted.
+func (s *sentinelConfig) query() (masterAddr string, slaveAddrs []string, err error) {
+	err = ErrNoSentinel
+	for _, addr := range s.addrs {
+		var conn Conn
+		conn, err = DialTimeout("tcp", addr, sentinelTimeout, sentinelTimeout, sentinelTimeout)
+		if err != nil {
+			continue
+		}
+		masterAddr, slaveAddrs, err = s.queryConn(conn)
+		conn.Close()
+		if err == nil {
+			return masterAddr, slaveAddrs, nil
+		}
+	}
+	return "", nil, err
+}
+
+func (s *sentinelConfig) queryConn(conn Conn) (string, []string, error) {
+	hostPort, err := Strings(conn.Do("SENTINEL", "get-master-addr-by-name", s.masterName))
+	if err == ErrNil {
+		return "", nil, fmt.Errorf("Sentinel does not know master %q", s.masterName)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if len(hostPort) != 2 {
+		return "", nil, errors.New("Unexpected reply to SENTINEL get-master-addr-by-name")
+	}
+	masterAddr := net.JoinHostPort(hostPort[0], hostPort[1])
+
+	replies, err := Values(conn.Do("SENTINEL", "slaves", s.masterName))
+	if err != nil {
+		return "", nil, err
+	}
+	var slaveAddrs []string
+	for _, reply := range replies {
+		fields, err := Strings(reply, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		info := make(map[string]string, len(fields)/2)
+		for i := 0; i+1 < len(fields); i += 2 {
+			info[fields[i]] = fields[i+1]
+		}
+		if slaveDown(info["flags"]) {
+			continue
+		}
+		slaveAddrs = append(slaveAddrs, net.JoinHostPort(info["ip"], info["port"]))
+	}
+	sort.Strings(slaveAddrs)
+	return masterAddr, slaveAddrs, nil
+}
+
+// Return true if the sentinel flags for a slave show that it can't serve
+// reads.
+func slaveDown(flags string) bool {
+	for _, flag := range strings.Split(flags, ",") {
+		switch flag {
+		case "s_down", "o_down", "disconnected":
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+// Copyright 2013 Tahir Hashmi, Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSentinel answers SENTINEL queries from a topology that tests can
+// change.
+type fakeSentinel struct {
+	mu     sync.Mutex
+	master string
+	slaves []string // "ip port flags"
+}
+
+func (s *fakeSentinel) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(args) != 3 || args[0] != "SENTINEL" || args[2] != "mymaster" {
+		return "-ERR unknown command\r\n"
+	}
+	switch args[1] {
+	case "get-master-addr-by-name":
+		p := strings.Fields(s.master)
+		return fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(p[0]), p[0], len(p[1]), p[1])
+	case "slaves":
+		reply := fmt.Sprintf("*%d\r\n", len(s.slaves))
+		for _, slave := range s.slaves {
+			p := strings.Fields(slave)
+			reply += "*6\r\n"
+			for i, k := range []string{"ip", "port", "flags"} {
+				reply += fmt.Sprintf("$%d\r\n%s\r\n$%d\r\n%s\r\n", len(k), k, len(p[i]), p[i])
+			}
+		}
+		return reply
+	}
+	return "-ERR unknown subcommand\r\n"
+}
+
+func (s *fakeSentinel) set(master string, slaves ...string) {
+	s.mu.Lock()
+	s.master = master
+	s.slaves = slaves
+	s.mu.Unlock()
+}
+
+type poolFactory struct {
+	t       *testing.T
+	mu      sync.Mutex
+	pools   map[string]*Pool
+	created []string
+}
+
+func (f *poolFactory) dial(addr string) (*Pool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := &dialer{t: f.t}
+	p := &Pool{Dial: d.dial}
+	f.pools[addr] = p
+	f.created = append(f.created, addr)
+	return p, nil
+}
+
+func TestNewClusterFromSentinel(t *testing.T) {
+	fs := &fakeSentinel{}
+	fs.set("10.0.0.1 6379", "10.0.0.2 6379 slave", "10.0.0.3 6379 slave,s_down")
+	srv := NewFakeServer(t, fs.handle)
+	defer srv.Close()
+
+	f := &poolFactory{t: t, pools: make(map[string]*Pool)}
+	c, err := NewClusterFromSentinel([]string{"127.0.0.1:1", srv.Addr()}, "mymaster", f.dial)
+	if err != nil {
+		t.Fatalf("NewClusterFromSentinel returned %v", err)
+	}
+	if p := slavePool(c.GetMasterConn()); p != f.pools["10.0.0.1:6379"] {
+		t.Errorf("master pool = %p, want pool for 10.0.0.1:6379", p)
+	}
+	if s := c.Slaves(); len(s) != 1 || s[0] != f.pools["10.0.0.2:6379"] {
+		t.Errorf("slaves = %v, want pool for 10.0.0.2:6379 only", s)
+	}
+
+	// No change in topology should not create new pools.
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("Refresh() returned %v", err)
+	}
+	if len(f.created) != 2 {
+		t.Errorf("created pools for %v, want 2 pools", f.created)
+	}
+
+	oldMaster := f.pools["10.0.0.1:6379"]
+	fs.set("10.0.0.2 6379", "10.0.0.1 6379 slave", "10.0.0.3 6379 slave")
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("Refresh() returned %v", err)
+	}
+	if !oldMaster.closed {
+		t.Errorf("old master pool not closed")
+	}
+	if p := slavePool(c.GetMasterConn()); p != f.pools["10.0.0.2:6379"] {
+		t.Errorf("master pool = %p, want pool for 10.0.0.2:6379", p)
+	}
+	if n := c.SlaveCount(); n != 2 {
+		t.Errorf("SlaveCount() = %d, want 2", n)
+	}
+	if len(f.created) != 5 {
+		t.Errorf("created pools for %v, want 5 pools", f.created)
+	}
+
+	// A slave that goes down is removed without recreating the others.
+	kept := f.pools["10.0.0.3:6379"]
+	fs.set("10.0.0.2 6379", "10.0.0.1 6379 slave,disconnected", "10.0.0.3 6379 slave")
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("Refresh() returned %v", err)
+	}
+	if s := c.Slaves(); len(s) != 1 || s[0] != kept || kept.closed {
+		t.Errorf("slaves = %v, want open pool %p", s, kept)
+	}
+	if len(f.created) != 5 {
+		t.Errorf("created pools for %v, want 5 pools", f.created)
+	}
+
+	if err := (&Cluster{}).Refresh(); err != ErrNotSentinelCluster {
+		t.Errorf("Refresh() on plain cluster returned %v, want %v", err, ErrNotSentinelCluster)
+	}
+}
+
+func TestNewClusterFromSentinelUnreachable(t *testing.T) {
+	f := &poolFactory{t: t, pools: make(map[string]*Pool)}
+	if _, err := NewClusterFromSentinel([]string{"127.0.0.1:1"}, "mymaster", f.dial); err == nil {
+		t.Errorf("NewClusterFromSentinel with no reachable sentinel returned nil error")
+	}
+	if _, err := NewClusterFromSentinel(nil, "mymaster", f.dial); err != ErrNoSentinel {
+		t.Errorf("NewClusterFromSentinel(nil, ...) returned %v, want %v", err, ErrNoSentinel)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"testing"
+)
+
+// FakeServer is a minimal in-process Redis server for tests that do not
+// need a real server. The handler returns the raw reply to send for each
+// command. A handler returning "" sends no reply.
+type FakeServer struct {
+	l       net.Listener
+	handler func(args []string) string
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// NewFakeServer starts a server listening on a local TCP port.
+func NewFakeServer(t *testing.T, handler func(args []string) string) *FakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen returned %v", err)
+	}
+	s := &FakeServer{l: l, handler: handler}
+	go s.serve()
+	return s
+}
+
+// NewFakeTLSServer starts a server accepting TLS connections on a local TCP
+// port.
+func NewFakeTLSServer(t *testing.T, config *tls.Config, handler func(args []string) string) *FakeServer {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("tls.Listen returned %v", err)
+	}
+	s := &FakeServer{l: l, handler: handler}
+	go s.serve()
+	return s
+}
+
+// Addr returns the address of the server.
+func (s *FakeServer) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Close stops the server and closes all client connections.
+func (s *FakeServer) Close() {
+	s.l.Close()
+	s.DropConns()
+}
+
+// DropConns closes all client connections. The server continues to accept
+// new connections.
+func (s *FakeServer) DropConns() {
+	s.mu.Lock()
+	for _, nc := range s.conns {
+		nc.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+}
+
+func (s *FakeServer) serve() {
+	for {
+		nc, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, nc)
+		s.mu.Unlock()
+		go s.serveConn(nc)
+	}
+}
+
+func (s *FakeServer) serveConn(nc net.Conn) {
+	defer nc.Close()
+	// Commands sent by clients are multi-bulk values of bulk strings, so
+	// the client reply reader doubles as a command parser.
+	c := NewConn(nc, 0, 0)
+	for {
+		args, err := Strings(c.Receive())
+		if err != nil {
+			return
+		}
+		if reply := s.handler(args); reply != "" {
+			if _, err := nc.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}
+}
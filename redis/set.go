@@ -0,0 +1,178 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SetOptions specifies the options of the SET command.
+type SetOptions struct {
+	// Only set the key if it does not exist.
+	NX bool
+
+	// Only set the key if it already exists.
+	XX bool
+
+	// Retain the time to live of the existing key. KEEPTTL cannot be
+	// combined with a ttl.
+	KEEPTTL bool
+
+	// Ask the server for the old value of the key. The old value is used
+	// to find out whether the key was set with NX or XX; it is not returned.
+	GET bool
+}
+
+// SetWithTTL sets key to value using the SET command and reports whether the
+// value was set. A value is not set when the NX or XX condition is not met.
+//
+// If ttl is positive, then the key expires after ttl. Whole seconds are sent
+// with the EX option, other durations are sent in milliseconds with the PX
+// option. If ttl is zero, then the key does not expire unless KEEPTTL is set.
+func SetWithTTL(c Conn, key string, value interface{}, ttl time.Duration, opts SetOptions) (bool, error) {
+	switch {
+	case ttl < 0:
+		return false, errors.New("redigo: SetWithTTL requires a ttl that is not negative")
+	case opts.NX && opts.XX:
+		return false, errors.New("redigo: SetWithTTL options NX and XX are mutually exclusive")
+	case opts.KEEPTTL && ttl > 0:
+		return false, errors.New("redigo: SetWithTTL option KEEPTTL cannot be combined with a ttl")
+	}
+
+	args := []interface{}{key, value}
+	switch {
+	case ttl%time.Second == 0 && ttl > 0:
+		args = append(args, "EX", int64(ttl/time.Second))
+	case ttl > 0:
+		ms := int64(ttl / time.Millisecond)
+		if ms == 0 {
+			// Round up durations shorter than the resolution of PX.
+			ms = 1
+		}
+		args = append(args, "PX", ms)
+	case opts.KEEPTTL:
+		args = append(args, "KEEPTTL")
+	}
+	switch {
+	case opts.NX:
+		args = append(args, "NX")
+	case opts.XX:
+		args = append(args, "XX")
+	}
+	if opts.GET {
+		args = append(args, "GET")
+	}
+
+	reply, err := c.Do("SET", args...)
+	if err != nil {
+		return false, err
+	}
+	if opts.GET {
+		// The reply is the old value, or nil if the key did not exist.
+		switch {
+		case opts.NX:
+			return reply == nil, nil
+		case opts.XX:
+			return reply != nil, nil
+		}
+		return true, nil
+	}
+	if reply == nil {
+		return false, nil
+	}
+	s, err := String(reply, nil)
+	if err != nil {
+		return false, err
+	}
+	return s == "OK", nil
+}
+
+// GetDel returns the value of key and deletes the key using the GETDEL
+// command. If the key does not exist, then GetDel returns a nil value.
+// GETDEL requires Redis 6.2 or later.
+func GetDel(c Conn, key string) ([]byte, error) {
+	reply, err := c.Do("GETDEL", key)
+	return getReply("GETDEL", reply, err)
+}
+
+// GetExOptions specifies the options of the GETEX command. At most one
+// option can be set. If no option is set, then the time to live of the key
+// is not changed.
+type GetExOptions struct {
+	// Expire the key after the duration, sent in seconds rounded up.
+	EX time.Duration
+
+	// Expire the key after the duration, sent in milliseconds rounded up.
+	PX time.Duration
+
+	// Expire the key at the time, sent as a Unix time in seconds.
+	EXAT time.Time
+
+	// Expire the key at the time, sent as a Unix time in milliseconds.
+	PXAT time.Time
+
+	// Remove the time to live of the key.
+	PERSIST bool
+}
+
+// GetEx returns the value of key and sets the expiration of the key using
+// the GETEX command. If the key does not exist, then GetEx returns a nil
+// value. GETEX requires Redis 6.2 or later.
+func GetEx(c Conn, key string, opts GetExOptions) ([]byte, error) {
+	if opts.EX < 0 || opts.PX < 0 {
+		return nil, errors.New("redigo: GetEx requires a duration that is not negative")
+	}
+	args := []interface{}{key}
+	n := 0
+	if opts.EX > 0 {
+		args = append(args, "EX", int64((opts.EX+time.Second-1)/time.Second))
+		n++
+	}
+	if opts.PX > 0 {
+		args = append(args, "PX", int64((opts.PX+time.Millisecond-1)/time.Millisecond))
+		n++
+	}
+	if !opts.EXAT.IsZero() {
+		args = append(args, "EXAT", opts.EXAT.Unix())
+		n++
+	}
+	if !opts.PXAT.IsZero() {
+		args = append(args, "PXAT", opts.PXAT.UnixNano()/int64(time.Millisecond))
+		n++
+	}
+	if opts.PERSIST {
+		args = append(args, "PERSIST")
+		n++
+	}
+	if n > 1 {
+		return nil, errors.New("redigo: GetEx options EX, PX, EXAT, PXAT and PERSIST are mutually exclusive")
+	}
+	reply, err := c.Do("GETEX", args...)
+	return getReply("GETEX", reply, err)
+}
+
+// getReply converts the reply to a GETDEL or GETEX command.
+func getReply(cmd string, reply interface{}, err error) ([]byte, error) {
+	if isUnknownCommand(err) {
+		return nil, fmt.Errorf("redigo: %s requires Redis 6.2 or later: %v", cmd, err)
+	}
+	p, err := Bytes(reply, err)
+	if err == ErrNil {
+		return nil, nil
+	}
+	return p, err
+}
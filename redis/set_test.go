@@ -0,0 +1,179 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestSetWithTTL(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		// The key "old" exists with the value "v".
+		exists := args[1] == "old"
+		get := args[len(args)-1] == "GET"
+		switch {
+		case get && exists:
+			return "$1\r\nv\r\n"
+		case get:
+			return "$-1\r\n"
+		case strings.Contains(cmd, " NX") && exists, strings.Contains(cmd, " XX") && !exists:
+			return "$-1\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		key  string
+		ttl  time.Duration
+		opts redis.SetOptions
+		cmd  string
+		set  bool
+	}{
+		{"new", 0, redis.SetOptions{}, "SET new x", true},
+		{"new", time.Minute, redis.SetOptions{}, "SET new x EX 60", true},
+		{"new", 1500 * time.Millisecond, redis.SetOptions{NX: true}, "SET new x PX 1500 NX", true},
+		{"new", time.Microsecond, redis.SetOptions{}, "SET new x PX 1", true},
+		{"old", time.Second, redis.SetOptions{NX: true}, "SET old x EX 1 NX", false},
+		{"new", 0, redis.SetOptions{XX: true, KEEPTTL: true}, "SET new x KEEPTTL XX", false},
+		{"old", 0, redis.SetOptions{NX: true, GET: true}, "SET old x NX GET", false},
+		{"new", 0, redis.SetOptions{NX: true, GET: true}, "SET new x NX GET", true},
+		{"old", 0, redis.SetOptions{XX: true, GET: true}, "SET old x XX GET", true},
+		{"new", 0, redis.SetOptions{XX: true, GET: true}, "SET new x XX GET", false},
+		{"new", 0, redis.SetOptions{GET: true}, "SET new x GET", true},
+	}
+	for _, tt := range tests {
+		set, err := redis.SetWithTTL(c, tt.key, "x", tt.ttl, tt.opts)
+		if err != nil {
+			t.Errorf("SetWithTTL(%s, %v, %+v) returned error %v", tt.key, tt.ttl, tt.opts, err)
+			continue
+		}
+		if set != tt.set {
+			t.Errorf("SetWithTTL(%s, %v, %+v) = %v, want %v", tt.key, tt.ttl, tt.opts, set, tt.set)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("SetWithTTL(%s, %v, %+v) sent %q, want %q", tt.key, tt.ttl, tt.opts, cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestSetWithTTLInvalidOptions(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		opts redis.SetOptions
+	}{
+		{-time.Second, redis.SetOptions{}},
+		{0, redis.SetOptions{NX: true, XX: true}},
+		{time.Second, redis.SetOptions{KEEPTTL: true}},
+	}
+	for _, tt := range tests {
+		// The options are checked before the command is sent, so a nil
+		// connection is not used.
+		if _, err := redis.SetWithTTL(nil, "k", "x", tt.ttl, tt.opts); err == nil {
+			t.Errorf("SetWithTTL(%v, %+v) did not return an error", tt.ttl, tt.opts)
+		}
+	}
+}
+
+func TestGetDelGetEx(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		switch args[1] {
+		case "missing":
+			return "$-1\r\n"
+		case "old":
+			return "-ERR unknown command '" + args[0] + "'\r\n"
+		}
+		return "$1\r\nv\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	at := time.Unix(1700000000, 250*int64(time.Millisecond))
+	tests := []struct {
+		opts redis.GetExOptions
+		cmd  string
+	}{
+		{redis.GetExOptions{}, "GETEX k"},
+		{redis.GetExOptions{EX: 1500 * time.Millisecond}, "GETEX k EX 2"},
+		{redis.GetExOptions{PX: 1500 * time.Microsecond}, "GETEX k PX 2"},
+		{redis.GetExOptions{EXAT: at}, "GETEX k EXAT 1700000000"},
+		{redis.GetExOptions{PXAT: at}, "GETEX k PXAT 1700000000250"},
+		{redis.GetExOptions{PERSIST: true}, "GETEX k PERSIST"},
+	}
+	for _, tt := range tests {
+		v, err := redis.GetEx(c, "k", tt.opts)
+		if string(v) != "v" || err != nil {
+			t.Errorf("GetEx(%+v) returned %q, %v, want v", tt.opts, v, err)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("GetEx(%+v) sent %q, want %q", tt.opts, cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+	for _, opts := range []redis.GetExOptions{
+		{EX: time.Second, PERSIST: true},
+		{PX: time.Second, PXAT: at},
+		{EX: -time.Second},
+	} {
+		if _, err := redis.GetEx(c, "k", opts); err == nil {
+			t.Errorf("GetEx(%+v) returned nil error", opts)
+		}
+	}
+
+	if v, err := redis.GetDel(c, "k"); string(v) != "v" || err != nil {
+		t.Errorf("GetDel returned %q, %v, want v", v, err)
+	}
+	if v, err := redis.GetDel(c, "missing"); v != nil || err != nil {
+		t.Errorf("GetDel(missing) returned %q, %v, want nil", v, err)
+	}
+	if v, err := redis.GetEx(c, "missing", redis.GetExOptions{}); v != nil || err != nil {
+		t.Errorf("GetEx(missing) returned %q, %v, want nil", v, err)
+	}
+	if _, err := redis.GetDel(c, "old"); err == nil || !strings.Contains(err.Error(), "GETDEL requires Redis 6.2") {
+		t.Errorf("GetDel on old server returned %v, want version error", err)
+	}
+	if _, err := redis.GetEx(c, "old", redis.GetExOptions{}); err == nil || !strings.Contains(err.Error(), "GETEX requires Redis 6.2") {
+		t.Errorf("GetEx on old server returned %v, want version error", err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+// SMembers returns the members of the set at key using the SMEMBERS
+// command. An empty slice is returned for an empty set or a missing key.
+func SMembers(c Conn, key string) ([]string, error) {
+	return setMembers(c.Do("SMEMBERS", key))
+}
+
+// SRandMembers returns random members of the set at key using the
+// SRANDMEMBER command with a count. If count is positive, then up to count
+// distinct members are returned. If count is negative, then exactly -count
+// members are returned and a member may be returned more than once. An empty
+// slice is returned for an empty set or a missing key.
+func SRandMembers(c Conn, key string, count int) ([]string, error) {
+	return setMembers(c.Do("SRANDMEMBER", key, count))
+}
+
+func setMembers(reply interface{}, err error) ([]string, error) {
+	members, err := Strings(reply, err)
+	if err == ErrNil || (err == nil && members == nil) {
+		return []string{}, nil
+	}
+	return members, err
+}
@@ -0,0 +1,83 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestSetMembers(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		switch {
+		case args[1] == "empty":
+			return "*0\r\n"
+		case args[1] == "nil":
+			return "$-1\r\n"
+		case args[1] == "wrong":
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		case len(args) == 3 && args[2] == "-3":
+			return "*3\r\n$1\r\na\r\n$1\r\na\r\n$1\r\nb\r\n"
+		}
+		return "*2\r\n$1\r\na\r\n$1\r\nb\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		f       func() ([]string, error)
+		cmd     string
+		members []string
+	}{
+		{func() ([]string, error) { return redis.SMembers(c, "s") }, "SMEMBERS s", []string{"a", "b"}},
+		{func() ([]string, error) { return redis.SMembers(c, "empty") }, "SMEMBERS empty", []string{}},
+		{func() ([]string, error) { return redis.SRandMembers(c, "s", 2) }, "SRANDMEMBER s 2", []string{"a", "b"}},
+		{func() ([]string, error) { return redis.SRandMembers(c, "s", -3) }, "SRANDMEMBER s -3", []string{"a", "a", "b"}},
+		{func() ([]string, error) { return redis.SRandMembers(c, "nil", 1) }, "SRANDMEMBER nil 1", []string{}},
+	}
+	for _, tt := range tests {
+		members, err := tt.f()
+		if err != nil {
+			t.Errorf("%s returned error %v", tt.cmd, err)
+			continue
+		}
+		if members == nil || !reflect.DeepEqual(members, tt.members) {
+			t.Errorf("%s = %#v, want %#v", tt.cmd, members, tt.members)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("sent %q, want %q", cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+
+	if _, err := redis.SMembers(c, "wrong"); err == nil {
+		t.Error("SMembers of wrong type returned nil error")
+	}
+}
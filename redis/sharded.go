@@ -0,0 +1,147 @@
+// Copyright 2013 Tahir Hashmi, Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of MOVED or ASK redirections followed by DoSharded for
+// a single command.
+const maxRedirects = 16
+
+var ErrTooManyRedirects = errors.New("Too many Redis Cluster redirections")
+
+// Register the pool for a Redis Cluster node by its host:port address.
+// DoSharded uses registered pools when it is redirected to the node.
+func (c *Cluster) AddNode(addr string, p *Pool) error {
+	if p == nil {
+		return ErrNilPool
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.nodes == nil {
+		c.nodes = make(map[string]*Pool)
+	}
+	c.nodes[addr] = p
+	return nil
+}
+
+// Run a command on the Redis Cluster node that owns the hash slot of
+// key. Until the cluster learns which node owns a slot, commands are
+// sent to the master. On a MOVED redirection the command is sent again
+// to the indicated node and the node is remembered as the owner of the
+// slot. On an ASK redirection the command is sent once to the indicated
+// node, preceded by ASKING, without changing the slot owner. Pools for
+// nodes that were not registered with AddNode are created using
+// Cluster.DialNode.
+func (c *Cluster) DoSharded(key string, cmd string, args ...interface{}) (interface{}, error) {
+	slot := Slot(key)
+	c.mu.RLock()
+	p := c.master
+	if c.slots != nil && c.slots[slot] != nil {
+		p = c.slots[slot]
+	}
+	c.mu.RUnlock()
+	if p == nil {
+		return nil, ErrNoMaster
+	}
+
+	asking := false
+	for i := 0; i <= maxRedirects; i++ {
+		conn := p.Get()
+		if asking {
+			// Check the reply to ASKING separately so that its error is
+			// not mistaken for the error of the command.
+			if _, err := conn.Do("ASKING"); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		reply, err := conn.Do(cmd, args...)
+		conn.Close()
+
+		e, ok := err.(Error)
+		if !ok {
+			return reply, err
+		}
+		kind, addr, ok := parseRedirect(e)
+		if !ok {
+			return reply, err
+		}
+		if p, err = c.node(addr); err != nil {
+			return nil, err
+		}
+		asking = kind == "ASK"
+		if !asking {
+			c.mu.Lock()
+			if c.slots == nil {
+				c.slots = make([]*Pool, numSlots)
+			}
+			c.slots[slot] = p
+			c.mu.Unlock()
+		}
+	}
+	return nil, ErrTooManyRedirects
+}
+
+// Return the pool for the node at addr, creating it with
+// Cluster.DialNode if the node is not known yet.
+func (c *Cluster) node(addr string) (*Pool, error) {
+	c.mu.RLock()
+	p := c.nodes[addr]
+	c.mu.RUnlock()
+	if p != nil {
+		return p, nil
+	}
+	if c.DialNode == nil {
+		return nil, fmt.Errorf("Redirected to unknown node %s and Cluster.DialNode is not set", addr)
+	}
+
+	// Dial without holding the lock so that a slow node does not block
+	// commands to the other nodes. If another goroutine registered the
+	// node in the meantime, its pool is kept and this one is closed.
+	p, err := c.DialNode(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if existing := c.nodes[addr]; existing != nil {
+		c.mu.Unlock()
+		p.Close()
+		return existing, nil
+	}
+	if c.nodes == nil {
+		c.nodes = make(map[string]*Pool)
+	}
+	c.nodes[addr] = p
+	c.mu.Unlock()
+	return p, nil
+}
+
+// Parse a "MOVED <slot> <addr>" or "ASK <slot> <addr>" error reply.
+func parseRedirect(e Error) (kind string, addr string, ok bool) {
+	f := strings.Fields(string(e))
+	if len(f) != 3 || (f[0] != "MOVED" && f[0] != "ASK") {
+		return "", "", false
+	}
+	if _, err := strconv.ParseUint(f[1], 10, 16); err != nil {
+		return "", "", false
+	}
+	return f[0], f[2], true
+}
@@ -0,0 +1,216 @@
+// Copyright 2013 Tahir Hashmi, Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func serverPool(addr string) *Pool {
+	return NewPool(func() (Conn, error) { return Dial("tcp", addr) }, 2)
+}
+
+type commandLog struct {
+	mu   sync.Mutex
+	cmds []string
+}
+
+func (l *commandLog) add(args []string) {
+	l.mu.Lock()
+	l.cmds = append(l.cmds, args[0])
+	l.mu.Unlock()
+}
+
+func (l *commandLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.cmds...)
+}
+
+func TestClusterDoSharded(t *testing.T) {
+	var movedLog, askLog commandLog
+
+	target := NewFakeServer(t, func(args []string) string {
+		askLog.add(args)
+		if args[0] == "ASKING" {
+			return "+OK\r\n"
+		}
+		return "$6\r\ntarget\r\n"
+	})
+	defer target.Close()
+
+	owner := NewFakeServer(t, func(args []string) string {
+		movedLog.add(args)
+		if args[1] == "migrating" {
+			return fmt.Sprintf("-ASK %d %s\r\n", Slot(args[1]), target.Addr())
+		}
+		return "$5\r\nowner\r\n"
+	})
+	defer owner.Close()
+
+	master := NewFakeServer(t, func(args []string) string {
+		return fmt.Sprintf("-MOVED %d %s\r\n", Slot(args[1]), owner.Addr())
+	})
+	defer master.Close()
+
+	var dialed []string
+	c := &Cluster{DialNode: func(addr string) (*Pool, error) {
+		dialed = append(dialed, addr)
+		return serverPool(addr), nil
+	}}
+	c.AddMaster(serverPool(master.Addr()))
+	defer c.TearDown()
+
+	for i := 0; i < 2; i++ {
+		s, err := String(c.DoSharded("foo", "GET", "foo"))
+		if err != nil || s != "owner" {
+			t.Fatalf("DoSharded(foo) = %q, %v, want owner", s, err)
+		}
+	}
+	if len(dialed) != 1 || dialed[0] != owner.Addr() {
+		t.Fatalf("dialed %v, want [%s]", dialed, owner.Addr())
+	}
+	// The second command goes straight to the owner of the slot.
+	if n := len(movedLog.get()); n != 2 {
+		t.Fatalf("owner received %d commands, want 2", n)
+	}
+
+	c.AddNode(target.Addr(), serverPool(target.Addr()))
+	for i := 0; i < 2; i++ {
+		s, err := String(c.DoSharded("migrating", "GET", "migrating"))
+		if err != nil || s != "target" {
+			t.Fatalf("DoSharded(migrating) = %q, %v, want target", s, err)
+		}
+	}
+	// ASK does not change the owner of the slot, so the second command is
+	// sent to the owner again.
+	if n := len(movedLog.get()); n != 4 {
+		t.Fatalf("owner received %d commands, want 4", n)
+	}
+	want := []string{"ASKING", "GET", "ASKING", "GET"}
+	if got := askLog.get(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("target received %v, want %v", got, want)
+	}
+	if len(dialed) != 1 {
+		t.Fatalf("dialed %v, want only the owner", dialed)
+	}
+}
+
+func TestClusterDoShardedErrors(t *testing.T) {
+	var s *FakeServer
+	s = NewFakeServer(t, func(args []string) string {
+		if args[0] == "LOOP" {
+			return fmt.Sprintf("-MOVED %d %s\r\n", Slot(args[1]), s.Addr())
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer s.Close()
+
+	c := &Cluster{}
+	if _, err := c.DoSharded("foo", "GET", "foo"); err != ErrNoMaster {
+		t.Fatalf("DoSharded without master returned %v, want %v", err, ErrNoMaster)
+	}
+
+	p := serverPool(s.Addr())
+	c.AddMaster(p)
+	c.AddNode(s.Addr(), p)
+	if _, err := c.DoSharded("foo", "LOOP", "foo"); err != ErrTooManyRedirects {
+		t.Fatalf("DoSharded with redirect loop returned %v, want %v", err, ErrTooManyRedirects)
+	}
+	if _, err := c.DoSharded("foo", "BOGUS", "foo"); err == nil || err.Error() != "ERR unknown command" {
+		t.Fatalf("DoSharded(BOGUS) returned %v, want server error", err)
+	}
+
+	c = &Cluster{}
+	c.AddMaster(serverPool(s.Addr()))
+	if _, err := c.DoSharded("foo", "LOOP", "foo"); err == nil || err == ErrTooManyRedirects {
+		t.Fatalf("DoSharded to unknown node without DialNode returned %v", err)
+	}
+
+	// A failed ASKING is reported and the command is not sent.
+	var targetLog commandLog
+	target := NewFakeServer(t, func(args []string) string {
+		targetLog.add(args)
+		return "-ERR ASKING failed\r\n"
+	})
+	defer target.Close()
+	owner := NewFakeServer(t, func(args []string) string {
+		return fmt.Sprintf("-ASK %d %s\r\n", Slot(args[1]), target.Addr())
+	})
+	defer owner.Close()
+	c = &Cluster{}
+	c.AddMaster(serverPool(owner.Addr()))
+	c.AddNode(target.Addr(), serverPool(target.Addr()))
+	if _, err := c.DoSharded("foo", "GET", "foo"); err == nil || err.Error() != "ERR ASKING failed" {
+		t.Fatalf("DoSharded with failed ASKING returned %v, want ERR ASKING failed", err)
+	}
+	if got := targetLog.get(); fmt.Sprint(got) != "[ASKING]" {
+		t.Fatalf("target received %v, want [ASKING]", got)
+	}
+}
+
+func TestClusterNodeDialsWithoutLock(t *testing.T) {
+	started := make(chan bool)
+	release := make(chan bool)
+	var mu sync.Mutex
+	var pools []*Pool
+	c := &Cluster{DialNode: func(addr string) (*Pool, error) {
+		started <- true
+		<-release
+		p := NewPool(func() (Conn, error) { return nil, fmt.Errorf("not dialed") }, 1)
+		mu.Lock()
+		pools = append(pools, p)
+		mu.Unlock()
+		return p, nil
+	}}
+
+	results := make(chan *Pool, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			p, err := c.node("slow:6379")
+			if err != nil {
+				t.Errorf("node returned %v", err)
+			}
+			results <- p
+		}()
+	}
+	<-started
+	<-started
+
+	// Other nodes are usable while DialNode is running.
+	other := NewPool(nil, 1)
+	c.AddNode("other:6379", other)
+	if p, err := c.node("other:6379"); p != other || err != nil {
+		t.Fatalf("node(other) = %p, %v, want %p", p, err, other)
+	}
+
+	close(release)
+	p1, p2 := <-results, <-results
+	if p1 != p2 || p1 == nil {
+		t.Fatalf("concurrent node calls returned %p and %p, want the same pool", p1, p2)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range pools {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed != (p != p1) {
+			t.Errorf("pool %p closed = %v, want only the duplicate closed", p, closed)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2013 Tahir Hashmi, Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Expected values are the replies of CLUSTER KEYSLOT.
+var slotTests = []struct {
+	key  string
+	slot uint16
+}{
+	{"", 0},
+	{"123456789", 12739},
+	{"foo", 12182},
+	{"bar", 5061},
+	{"hello", 866},
+	{"somekey", 11058},
+	{"foo{hash_tag}", 2515},
+	{"somekey{hash_tag}", 2515},
+	{"{hash_tag}", 2515},
+	{"hash_tag", 2515},
+	{"foo{bar}{zap}", 5061},
+}
+
+func TestSlot(t *testing.T) {
+	for _, tt := range slotTests {
+		if slot := redis.Slot(tt.key); slot != tt.slot {
+			t.Errorf("Slot(%q) = %d, want %d", tt.key, slot, tt.slot)
+		}
+	}
+	if redis.Slot("{user1000}.following") != redis.Slot("{user1000}.followers") {
+		t.Error("keys with the same hash tag map to different slots")
+	}
+	if redis.Slot("{}foo") == redis.Slot("foo") {
+		t.Error("empty hash tag was not hashed as part of the key")
+	}
+	if redis.Slot("foo{{bar}}zap") != redis.Slot("{bar") {
+		t.Error("hash tag does not end at the first '}'")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"time"
+)
+
+// XAdd appends an entry with the given fields to a stream using the XADD
+// command and returns the ID of the entry. If id is "", then the server
+// generates the ID. The fields are sent in sorted order.
+func XAdd(c Conn, stream string, id string, fields map[string]interface{}) (string, error) {
+	if len(fields) == 0 {
+		return "", errors.New("redigo: XAdd requires at least one field")
+	}
+	if id == "" {
+		id = "*"
+	}
+	args := Args{stream, id}.AddFlat(fields)
+	return String(c.Do("XADD", args...))
+}
+
+// XReadArgs specifies the arguments of the XREAD command.
+type XReadArgs struct {
+	// Names of the streams to read.
+	Streams []string
+
+	// For each stream, the ID after which entries are read. Use "$" to
+	// read only entries added while the command blocks.
+	IDs []string
+
+	// Maximum number of entries to read from each stream. If zero, then
+	// there is no limit.
+	Count int
+
+	// Time to wait for entries when none are available. If zero, then
+	// XRead does not wait. If negative, then XRead waits until an entry
+	// is added. The read timeout of the connection must be longer than
+	// Block for the reply to be received.
+	Block time.Duration
+}
+
+// XRead reads entries from one or more streams using the XREAD command.
+// The entries are returned as by StreamsRead. If no entry is available
+// before the block time ends, then XRead returns nil, ErrNil.
+func XRead(c Conn, args XReadArgs) (map[string][]StreamEntry, error) {
+	if len(args.Streams) == 0 || len(args.Streams) != len(args.IDs) {
+		return nil, errors.New("redigo: XRead requires one ID for each of one or more streams")
+	}
+	var a []interface{}
+	if args.Count > 0 {
+		a = append(a, "COUNT", args.Count)
+	}
+	switch {
+	case args.Block > 0:
+		ms := int64(args.Block / time.Millisecond)
+		if ms == 0 {
+			// BLOCK 0 waits forever, round up shorter durations.
+			ms = 1
+		}
+		a = append(a, "BLOCK", ms)
+	case args.Block < 0:
+		a = append(a, "BLOCK", 0)
+	}
+	a = append(a, "STREAMS")
+	for _, s := range args.Streams {
+		a = append(a, s)
+	}
+	for _, id := range args.IDs {
+		a = append(a, id)
+	}
+	return StreamsRead(c.Do("XREAD", a...))
+}
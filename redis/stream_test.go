@@ -0,0 +1,100 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestStreamCommands(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		switch args[0] {
+		case "XADD":
+			return "$3\r\n1-0\r\n"
+		case "XREAD":
+			if args[len(args)-1] == "$" {
+				return "*-1\r\n"
+			}
+			return "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\nk\r\n$1\r\nv\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	lastCmd := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return cmd
+	}
+
+	id, err := redis.XAdd(c, "s", "", map[string]interface{}{"k": "v", "a": 1})
+	if err != nil || id != "1-0" {
+		t.Errorf("XAdd returned %q, %v, want 1-0, nil", id, err)
+	}
+	if want := "XADD s * a 1 k v"; lastCmd() != want {
+		t.Errorf("XAdd sent %q, want %q", lastCmd(), want)
+	}
+
+	streams, err := redis.XRead(c, redis.XReadArgs{Streams: []string{"s"}, IDs: []string{"0"}, Count: 10})
+	want := map[string][]redis.StreamEntry{"s": {{ID: "1-0", Fields: map[string]string{"k": "v"}}}}
+	if err != nil || !reflect.DeepEqual(streams, want) {
+		t.Errorf("XRead returned %v, %v, want %v", streams, err, want)
+	}
+	if want := "XREAD COUNT 10 STREAMS s 0"; lastCmd() != want {
+		t.Errorf("XRead sent %q, want %q", lastCmd(), want)
+	}
+
+	blockTests := []struct {
+		block time.Duration
+		cmd   string
+	}{
+		{1500 * time.Millisecond, "XREAD BLOCK 1500 STREAMS s $"},
+		{time.Microsecond, "XREAD BLOCK 1 STREAMS s $"},
+		{-1, "XREAD BLOCK 0 STREAMS s $"},
+	}
+	for _, tt := range blockTests {
+		_, err := redis.XRead(c, redis.XReadArgs{Streams: []string{"s"}, IDs: []string{"$"}, Block: tt.block})
+		if err != redis.ErrNil {
+			t.Errorf("XRead with Block %v returned %v, want %v", tt.block, err, redis.ErrNil)
+		}
+		if lastCmd() != tt.cmd {
+			t.Errorf("XRead with Block %v sent %q, want %q", tt.block, lastCmd(), tt.cmd)
+		}
+	}
+
+	if _, err := redis.XRead(c, redis.XReadArgs{Streams: []string{"s"}}); err == nil {
+		t.Error("XRead without IDs returned nil error")
+	}
+	if _, err := redis.XAdd(c, "s", "*", nil); err == nil {
+		t.Error("XAdd without fields returned nil error")
+	}
+}
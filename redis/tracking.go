@@ -0,0 +1,140 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"sync"
+)
+
+// TrackingOptions specifies the options of a TrackingConn.
+type TrackingOptions struct {
+	// Use broadcasting mode. In broadcasting mode, the server sends an
+	// invalidation for every modified key that matches one of Prefixes,
+	// whether or not the key was read on the connection.
+	Broadcast bool
+
+	// Key prefixes for broadcasting mode. If empty, then invalidations are
+	// sent for all keys. Prefixes require Broadcast.
+	Prefixes []string
+
+	// Do not send invalidations for keys modified by the connection itself.
+	NoLoop bool
+
+	// Buffer size of the channel returned by Invalidations.
+	BufferSize int
+}
+
+// TrackingConn is a connection with server assisted client side caching
+// enabled using the CLIENT TRACKING command. The keys invalidated by the
+// server are sent on the channel returned by the Invalidations method so
+// that the application can remove them from a local cache.
+//
+// In the default mode, the server remembers the keys read on the connection
+// and sends a single invalidation when one of the keys is modified. The
+// server uses memory for each key read by each tracking client, up to the
+// tracking-table-max-keys limit of the server, after which the server evicts
+// keys from the table and sends invalidations for the evicted keys. Reading
+// many distinct keys therefore increases memory use on the server and the
+// number of invalidations received. Broadcasting mode uses no memory for
+// each key, but sends invalidations for every modified key that matches a
+// prefix.
+//
+// Invalidations are pushed by the server on the same connection as command
+// replies. They are read while a command is executed with Do or while
+// Receive is called, so an idle connection delivers an invalidation with the
+// next command. Invalidations are sent on the channel by the goroutine that
+// reads the reply, and the read blocks while the channel is full. Receive
+// from the channel in a separate goroutine.
+type TrackingConn struct {
+	Conn
+	ch        chan []string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTrackingConn enables tracking on the connection and returns the
+// connection as a TrackingConn. The connection must be returned by Dial with
+// the DialProtocol(3) option.
+func NewTrackingConn(c Conn, opts TrackingOptions) (*TrackingConn, error) {
+	cn, ok := c.(*conn)
+	if !ok || cn.protocol != 3 {
+		return nil, errors.New("redigo: tracking requires a connection dialed with protocol version 3")
+	}
+	if len(opts.Prefixes) > 0 && !opts.Broadcast {
+		return nil, errors.New("redigo: tracking prefixes require broadcasting mode")
+	}
+
+	tc := &TrackingConn{
+		Conn: c,
+		ch:   make(chan []string, opts.BufferSize),
+		done: make(chan struct{}),
+	}
+	cn.onPush = tc.push
+
+	args := []interface{}{"TRACKING", "ON"}
+	if opts.Broadcast {
+		args = append(args, "BCAST")
+	}
+	for _, prefix := range opts.Prefixes {
+		args = append(args, "PREFIX", prefix)
+	}
+	if opts.NoLoop {
+		args = append(args, "NOLOOP")
+	}
+	if _, err := c.Do("CLIENT", args...); err != nil {
+		cn.onPush = nil
+		return nil, err
+	}
+	return tc, nil
+}
+
+// Invalidations returns the channel on which the invalidated keys are sent.
+// A nil slice is sent when the server flushes all keys, for example after
+// FLUSHALL, and the application should clear the whole cache. The channel
+// is not closed.
+func (tc *TrackingConn) Invalidations() <-chan []string {
+	return tc.ch
+}
+
+// Close closes the connection.
+func (tc *TrackingConn) Close() error {
+	tc.closeOnce.Do(func() { close(tc.done) })
+	return tc.Conn.Close()
+}
+
+// push sends the keys of an invalidation on the channel. Other pushes, such
+// as pub/sub messages, are returned as replies.
+func (tc *TrackingConn) push(p []interface{}) bool {
+	if len(p) != 2 {
+		return false
+	}
+	if kind, _ := String(p[0], nil); kind != "invalidate" {
+		return false
+	}
+	var keys []string
+	if p[1] != nil {
+		var err error
+		keys, err = Strings(p[1], nil)
+		if err != nil {
+			return false
+		}
+	}
+	select {
+	case tc.ch <- keys:
+	case <-tc.done:
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestTrackingConn(t *testing.T) {
+	var mu sync.Mutex
+	var tracking string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "HELLO":
+			return "%1\r\n+proto\r\n:3\r\n"
+		case "CLIENT":
+			mu.Lock()
+			tracking = strings.Join(args, " ")
+			mu.Unlock()
+			return "+OK\r\n"
+		case "GET":
+			// An invalidation pushed before the reply.
+			return ">2\r\n$10\r\ninvalidate\r\n*2\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nv\r\n"
+		case "FLUSHALL":
+			return "+OK\r\n>2\r\n$10\r\ninvalidate\r\n_\r\n"
+		case "SUBSCRIBE":
+			return ">3\r\n$9\r\nsubscribe\r\n$1\r\nc\r\n:1\r\n"
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr(), redis.DialProtocol(3))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	tc, err := redis.NewTrackingConn(c, redis.TrackingOptions{Broadcast: true, Prefixes: []string{"user:", "item:"}, NoLoop: true, BufferSize: 2})
+	if err != nil {
+		t.Fatalf("NewTrackingConn returned %v", err)
+	}
+	defer tc.Close()
+	mu.Lock()
+	if want := "CLIENT TRACKING ON BCAST PREFIX user: PREFIX item: NOLOOP"; tracking != want {
+		t.Errorf("sent %q, want %q", tracking, want)
+	}
+	mu.Unlock()
+
+	if v, err := redis.String(tc.Do("GET", "a")); err != nil || v != "v" {
+		t.Errorf("GET returned %q, %v, want v", v, err)
+	}
+	if keys := <-tc.Invalidations(); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("invalidated %q, want [a b]", keys)
+	}
+
+	// The invalidation after the reply is read with the next receive.
+	tc.Do("FLUSHALL")
+	tc.Send("SUBSCRIBE", "c")
+	tc.Flush()
+	reply, err := tc.Receive()
+	if want := []interface{}{[]byte("subscribe"), []byte("c"), int64(1)}; err != nil || !reflect.DeepEqual(reply, want) {
+		t.Errorf("Receive() = %v, %v, want %v", reply, err, want)
+	}
+	if keys := <-tc.Invalidations(); keys != nil {
+		t.Errorf("invalidated %q after FLUSHALL, want nil", keys)
+	}
+}
+
+func TestTrackingConnErrors(t *testing.T) {
+	s := redis.NewFakeServer(t, func(args []string) string { return "+OK\r\n" })
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c.Close()
+	if _, err := redis.NewTrackingConn(c, redis.TrackingOptions{}); err == nil {
+		t.Error("NewTrackingConn with protocol version 2 returned nil error")
+	}
+
+	c3, err := redis.Dial("tcp", s.Addr(), redis.DialProtocol(3))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer c3.Close()
+	if _, err := redis.NewTrackingConn(c3, redis.TrackingOptions{Prefixes: []string{"user:"}}); err == nil {
+		t.Error("NewTrackingConn with prefixes in default mode returned nil error")
+	}
+}
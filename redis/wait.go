@@ -0,0 +1,39 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+	"time"
+)
+
+// Wait blocks until the write commands previously sent on the connection
+// are acknowledged by numReplicas replicas or the timeout expires, using the
+// WAIT command, and returns the number of replicas that acknowledged the
+// writes. The count is returned without an error when the timeout expires
+// before enough replicas acknowledge. A zero timeout waits until enough
+// replicas acknowledge. The read timeout of the connection must be longer
+// than the timeout for the reply to be received.
+func Wait(c Conn, numReplicas int, timeout time.Duration) (int, error) {
+	if timeout < 0 {
+		return 0, errors.New("redigo: Wait requires a timeout that is not negative")
+	}
+	ms := int64(timeout / time.Millisecond)
+	if ms == 0 && timeout > 0 {
+		// WAIT 0 waits forever, round up shorter durations.
+		ms = 1
+	}
+	return Int(c.Do("WAIT", numReplicas, ms))
+}
@@ -0,0 +1,70 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestWait(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	// The fake master has one replica that acknowledges all writes.
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		return ":1\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		numReplicas int
+		timeout     time.Duration
+		cmd         string
+	}{
+		{1, time.Second, "WAIT 1 1000"},
+		// Fewer replicas than requested is not an error.
+		{2, 50 * time.Millisecond, "WAIT 2 50"},
+		{1, time.Microsecond, "WAIT 1 1"},
+		{1, 0, "WAIT 1 0"},
+	}
+	for _, tt := range tests {
+		n, err := redis.Wait(c, tt.numReplicas, tt.timeout)
+		if err != nil || n != 1 {
+			t.Errorf("Wait(%d, %v) = %d, %v, want 1, nil", tt.numReplicas, tt.timeout, n, err)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("Wait(%d, %v) sent %q, want %q", tt.numReplicas, tt.timeout, cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+
+	if _, err := redis.Wait(c, 1, -time.Second); err == nil {
+		t.Error("Wait with negative timeout returned nil error")
+	}
+}
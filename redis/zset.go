@@ -0,0 +1,109 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis
+
+import (
+	"errors"
+)
+
+// ZMember is a member of a sorted set and its score.
+type ZMember struct {
+	Score  float64
+	Member interface{}
+}
+
+// ZAddOptions specifies the options of the ZADD command.
+type ZAddOptions struct {
+	// Only add new members, do not update the score of existing members.
+	NX bool
+
+	// Only update the score of existing members, do not add new members.
+	XX bool
+
+	// Only update the score of existing members if the new score is
+	// greater than the current score.
+	GT bool
+
+	// Only update the score of existing members if the new score is less
+	// than the current score.
+	LT bool
+
+	// Count the members whose score changed in addition to the members
+	// added.
+	CH bool
+
+	// Increment the score of the member instead of setting it. INCR
+	// requires exactly one member.
+	INCR bool
+}
+
+// ZAdd adds members to the sorted set at key using the ZADD command and
+// returns the number of members added, or with the CH option, the number of
+// members added or changed. With the INCR option, ZAdd returns 1 if the score
+// of the member was incremented and 0 if the options prevented the update.
+//
+// The options are sent in the order required by the server. Options that
+// cannot be combined, NX with XX, GT or LT, and GT with LT, are rejected with
+// an error before the command is sent.
+func ZAdd(c Conn, key string, opts ZAddOptions, members ...ZMember) (int64, error) {
+	switch {
+	case len(members) == 0:
+		return 0, errors.New("redigo: ZAdd requires at least one member")
+	case opts.NX && opts.XX:
+		return 0, errors.New("redigo: ZAdd options NX and XX are mutually exclusive")
+	case opts.NX && (opts.GT || opts.LT):
+		return 0, errors.New("redigo: ZAdd option NX cannot be combined with GT or LT")
+	case opts.GT && opts.LT:
+		return 0, errors.New("redigo: ZAdd options GT and LT are mutually exclusive")
+	case opts.INCR && len(members) != 1:
+		return 0, errors.New("redigo: ZAdd option INCR requires exactly one member")
+	}
+
+	args := []interface{}{key}
+	switch {
+	case opts.NX:
+		args = append(args, "NX")
+	case opts.XX:
+		args = append(args, "XX")
+	}
+	switch {
+	case opts.GT:
+		args = append(args, "GT")
+	case opts.LT:
+		args = append(args, "LT")
+	}
+	if opts.CH {
+		args = append(args, "CH")
+	}
+	if opts.INCR {
+		args = append(args, "INCR")
+	}
+	for _, m := range members {
+		args = append(args, m.Score, m.Member)
+	}
+
+	if opts.INCR {
+		// The reply is the new score, or nil if the update was prevented.
+		reply, err := c.Do("ZADD", args...)
+		switch {
+		case err != nil:
+			return 0, err
+		case reply == nil:
+			return 0, nil
+		}
+		return 1, nil
+	}
+	return Int64(c.Do("ZADD", args...))
+}
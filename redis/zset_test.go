@@ -0,0 +1,100 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redis_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestZAdd(t *testing.T) {
+	var mu sync.Mutex
+	var cmd string
+	s := redis.NewFakeServer(t, func(args []string) string {
+		mu.Lock()
+		cmd = strings.Join(args, " ")
+		mu.Unlock()
+		for _, arg := range args {
+			if arg == "INCR" {
+				if args[len(args)-1] == "skip" {
+					return "$-1\r\n"
+				}
+				return "$3\r\n2.5\r\n"
+			}
+		}
+		return ":2\r\n"
+	})
+	defer s.Close()
+
+	c, err := redis.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("redis.Dial returned %v", err)
+	}
+	defer c.Close()
+
+	members := []redis.ZMember{{1, "a"}, {2.5, "b"}}
+	tests := []struct {
+		opts    redis.ZAddOptions
+		members []redis.ZMember
+		cmd     string
+		n       int64
+	}{
+		{redis.ZAddOptions{}, members, "ZADD z 1 a 2.5 b", 2},
+		{redis.ZAddOptions{CH: true, GT: true, XX: true}, members, "ZADD z XX GT CH 1 a 2.5 b", 2},
+		{redis.ZAddOptions{LT: true, CH: true}, members, "ZADD z LT CH 1 a 2.5 b", 2},
+		{redis.ZAddOptions{INCR: true, NX: true}, members[:1], "ZADD z NX INCR 1 a", 1},
+		{redis.ZAddOptions{INCR: true, XX: true}, []redis.ZMember{{1, "skip"}}, "ZADD z XX INCR 1 skip", 0},
+	}
+	for _, tt := range tests {
+		n, err := redis.ZAdd(c, "z", tt.opts, tt.members...)
+		if err != nil {
+			t.Errorf("ZAdd(%+v) returned error %v", tt.opts, err)
+			continue
+		}
+		if n != tt.n {
+			t.Errorf("ZAdd(%+v) = %d, want %d", tt.opts, n, tt.n)
+		}
+		mu.Lock()
+		if cmd != tt.cmd {
+			t.Errorf("ZAdd(%+v) sent %q, want %q", tt.opts, cmd, tt.cmd)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestZAddInvalidOptions(t *testing.T) {
+	members := []redis.ZMember{{1, "a"}, {2, "b"}}
+	tests := []struct {
+		opts    redis.ZAddOptions
+		members []redis.ZMember
+	}{
+		{redis.ZAddOptions{}, nil},
+		{redis.ZAddOptions{NX: true, XX: true}, members},
+		{redis.ZAddOptions{NX: true, GT: true}, members},
+		{redis.ZAddOptions{NX: true, LT: true}, members},
+		{redis.ZAddOptions{GT: true, LT: true}, members},
+		{redis.ZAddOptions{INCR: true}, members},
+	}
+	for _, tt := range tests {
+		// The options are checked before the command is sent, so a nil
+		// connection is not used.
+		if _, err := redis.ZAdd(nil, "z", tt.opts, tt.members...); err == nil {
+			t.Errorf("ZAdd(%+v, %v) did not return an error", tt.opts, tt.members)
+		}
+	}
+}
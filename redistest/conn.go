@@ -0,0 +1,219 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package redistest provides a connection for testing code that uses Redigo
+// without a Redis server.
+package redistest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Command is a command sent to a Conn.
+type Command struct {
+	Name string
+	Args []interface{}
+}
+
+type reply struct {
+	value interface{}
+	err   error
+}
+
+// Conn is a redis.Conn that records the commands sent by the application
+// and returns scripted replies. The replies added with AddReply are returned
+// in order, one for each command sent with Do or Send. A Receive with no
+// pending command also returns the next scripted reply, as when a message is
+// pushed to a subscribed connection.
+//
+//  c := redistest.NewConn()
+//  c.AddReply("OK", nil)
+//  c.SetError("GET", redis.Error("ERR broken"))
+//  err := codeUnderTest(c)
+//  if !reflect.DeepEqual(c.Commands(), want) {
+//      // handle unexpected commands
+//  }
+//
+// The methods of Conn are safe for concurrent use.
+type Conn struct {
+	mu       sync.Mutex
+	commands []Command
+	pending  []string
+	replies  []reply
+	errs     map[string]error
+	err      error
+}
+
+var _ redis.Conn = (*Conn)(nil)
+
+var errClosed = errors.New("redistest: connection closed")
+
+// NewConn returns a new connection with no scripted replies.
+func NewConn() *Conn {
+	return &Conn{errs: make(map[string]error)}
+}
+
+// AddReply adds a reply to the end of the scripted replies. The reply is
+// returned with err, which is usually nil or a redis.Error.
+func (c *Conn) AddReply(value interface{}, err error) {
+	c.mu.Lock()
+	c.replies = append(c.replies, reply{value, err})
+	c.mu.Unlock()
+}
+
+// SetError causes every command with the given name to return err instead of
+// a scripted reply. The name is not case sensitive. A nil err removes the
+// error for the command. As with the connection returned by redis.Dial, an
+// error that is not a redis.Error breaks the connection.
+func (c *Conn) SetError(commandName string, err error) {
+	c.mu.Lock()
+	if err == nil {
+		delete(c.errs, strings.ToUpper(commandName))
+	} else {
+		c.errs[strings.ToUpper(commandName)] = err
+	}
+	c.mu.Unlock()
+}
+
+// Commands returns the commands sent to the connection in order.
+func (c *Conn) Commands() []Command {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Command(nil), c.commands...)
+}
+
+// Close closes the connection. Commands sent after Close return an error.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == errClosed {
+		return errClosed
+	}
+	c.err = errClosed
+	return nil
+}
+
+// Err returns a non-nil value if the connection is closed.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Do records the command and returns its reply after reading the replies of
+// the commands sent before it, as does the connection returned by
+// redis.Dial. If commandName is "", then Do records no command and returns
+// the replies of the pending commands in a slice.
+func (c *Conn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	if commandName == "" {
+		values := make([]interface{}, len(c.pending))
+		for i := range values {
+			r := c.next()
+			if c.err != nil {
+				return nil, c.err
+			}
+			values[i] = r.value
+			if r.err != nil {
+				values[i] = r.err
+			}
+		}
+		return values, nil
+	}
+	c.send(commandName, args)
+	var err error
+	var value interface{}
+	for len(c.pending) > 0 {
+		r := c.next()
+		if c.err != nil {
+			return nil, c.err
+		}
+		value = r.value
+		if r.err != nil {
+			value = r.err
+			if err == nil {
+				err = r.err
+			}
+		}
+	}
+	return value, err
+}
+
+// Send records the command.
+func (c *Conn) Send(commandName string, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.send(commandName, args)
+	return nil
+}
+
+// Flush does nothing except to return an error if the connection is closed.
+func (c *Conn) Flush() error {
+	return c.Err()
+}
+
+// Receive returns the reply of the oldest pending command. If there are no
+// pending commands, Receive returns the next scripted reply.
+func (c *Conn) Receive() (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	r := c.next()
+	return r.value, r.err
+}
+
+func (c *Conn) send(commandName string, args []interface{}) {
+	c.commands = append(c.commands, Command{commandName, append([]interface{}(nil), args...)})
+	c.pending = append(c.pending, strings.ToUpper(commandName))
+}
+
+// next returns the reply of the oldest pending command, or the next scripted
+// reply if there are no pending commands. An error that is not a redis.Error
+// breaks the connection.
+func (c *Conn) next() (r reply) {
+	defer func() {
+		if _, ok := r.err.(redis.Error); r.err != nil && !ok {
+			c.err = r.err
+		}
+	}()
+	var name string
+	if len(c.pending) > 0 {
+		name, c.pending = c.pending[0], c.pending[1:]
+		if err, ok := c.errs[name]; ok {
+			return reply{err: err}
+		}
+	}
+	if len(c.replies) == 0 {
+		if name == "" {
+			return reply{err: errors.New("redistest: no scripted reply to receive")}
+		}
+		return reply{err: fmt.Errorf("redistest: no scripted reply for %s", name)}
+	}
+	r, c.replies = c.replies[0], c.replies[1:]
+	return r
+}
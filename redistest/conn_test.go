@@ -0,0 +1,117 @@
+// Copyright 2013 Gary Burd
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package redistest_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/garyburd/redigo/redistest"
+)
+
+func TestConn(t *testing.T) {
+	c := redistest.NewConn()
+	c.AddReply("OK", nil)
+	c.AddReply([]byte("bar"), nil)
+	c.AddReply(int64(1), nil)
+	c.AddReply(int64(2), nil)
+	c.AddReply(int64(3), nil)
+	c.SetError("del", redis.Error("ERR broken"))
+
+	if s, err := redis.String(c.Do("SET", "foo", "bar")); err != nil || s != "OK" {
+		t.Errorf("SET = %q, %v, want OK, nil", s, err)
+	}
+	if s, err := redis.String(c.Do("GET", "foo")); err != nil || s != "bar" {
+		t.Errorf("GET = %q, %v, want bar, nil", s, err)
+	}
+	if _, err := c.Do("DEL", "foo"); err == nil || err.Error() != "ERR broken" {
+		t.Errorf("DEL returned error %v, want ERR broken", err)
+	}
+
+	c.Send("INCR", "a")
+	c.Send("DEL", "a")
+	c.Send("INCR", "b")
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if n, err := redis.Int(c.Receive()); err != nil || n != 1 {
+		t.Errorf("Receive() = %d, %v, want 1, nil", n, err)
+	}
+	values, err := redis.Values(c.Do(""))
+	if err != nil {
+		t.Fatalf("Do(\"\") returned %v", err)
+	}
+	if want := []interface{}{redis.Error("ERR broken"), int64(2)}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Do(\"\") = %v, want %v", values, want)
+	}
+
+	// A Receive with no pending command returns the next scripted reply.
+	if n, err := redis.Int(c.Receive()); err != nil || n != 3 {
+		t.Errorf("Receive() = %d, %v, want 3, nil", n, err)
+	}
+
+	want := []redistest.Command{
+		{"SET", []interface{}{"foo", "bar"}},
+		{"GET", []interface{}{"foo"}},
+		{"DEL", []interface{}{"foo"}},
+		{"INCR", []interface{}{"a"}},
+		{"DEL", []interface{}{"a"}},
+		{"INCR", []interface{}{"b"}},
+	}
+	if cmds := c.Commands(); !reflect.DeepEqual(cmds, want) {
+		t.Errorf("Commands() = %v, want %v", cmds, want)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+	if _, err := c.Do("PING"); err == nil {
+		t.Error("Do after Close did not return an error")
+	}
+	if c.Err() == nil {
+		t.Error("Err after Close returned nil")
+	}
+}
+
+func TestConnBroken(t *testing.T) {
+	c := redistest.NewConn()
+	if _, err := c.Do("PING"); err == nil {
+		t.Error("Do with no scripted reply did not return an error")
+	}
+	if c.Err() == nil {
+		t.Error("Err returned nil after a missing reply")
+	}
+
+	c = redistest.NewConn()
+	errNetwork := errors.New("network down")
+	c.SetError("GET", errNetwork)
+	c.AddReply("OK", nil)
+	if _, err := c.Do("GET", "foo"); err != errNetwork {
+		t.Errorf("GET returned error %v, want %v", err, errNetwork)
+	}
+	if _, err := c.Do("SET", "foo", "bar"); err != errNetwork {
+		t.Errorf("SET on broken connection returned error %v, want %v", err, errNetwork)
+	}
+
+	c = redistest.NewConn()
+	c.SetError("GET", errNetwork)
+	c.SetError("GET", nil)
+	c.AddReply("bar", nil)
+	if s, err := redis.String(c.Do("GET", "foo")); err != nil || s != "bar" {
+		t.Errorf("GET after removing error = %q, %v, want bar, nil", s, err)
+	}
+}